@@ -0,0 +1,150 @@
+package bambulabs_cloud_api
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	maxAmsUnits  = 4   // Number of AMS/AMS Lite units that can be chained on one printer
+	traysPerAms  = 4   // Number of slots in a regular AMS/AMS Lite unit
+	amsHTFirstID = 128 // AMS HT units are numbered from 128 upwards
+	maxAmsHT     = 8   // Number of AMS HT units that can be attached
+	amsHTBitBase = 16  // Bit offset of the AMS HT single tray in the tray bitmasks
+
+	trayNowNone     = 255 // tray_now / tray_tar value when no filament is loaded
+	trayNowExternal = 254 // tray_now / tray_tar value for the external spool (vt_tray)
+)
+
+type AmsType int
+
+const (
+	AmsTypeUnknown AmsType = iota
+	AmsTypeAms
+	AmsTypeAmsLite
+	AmsTypeAms2Pro
+	AmsTypeAmsHT
+)
+
+func (t AmsType) String() string {
+	switch t {
+	case AmsTypeAms:
+		return "AMS"
+	case AmsTypeAmsLite:
+		return "AMS Lite"
+	case AmsTypeAms2Pro:
+		return "AMS 2 Pro"
+	case AmsTypeAmsHT:
+		return "AMS HT"
+	default:
+		return "Unknown"
+	}
+}
+
+// TrayLocation identifies a filament slot on the printer.
+type TrayLocation struct {
+	AmsID  int `json:"ams_id"`  // ID of the AMS unit, -1 for the external spool
+	TrayID int `json:"tray_id"` // Slot within the AMS unit
+}
+
+// External reports whether the location refers to the external spool holder.
+func (l TrayLocation) External() bool {
+	return l.AmsID < 0
+}
+
+// amsBits is a decoded AMS status bitmask such as tray_exist_bits.
+type amsBits uint64
+
+// parseAmsBits decodes a hex bitmask string as reported by the printer. Empty or
+// malformed strings decode to zero.
+func parseAmsBits(s string) amsBits {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "0x")
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0
+	}
+	return amsBits(v)
+}
+
+func (b amsBits) has(bit int) bool {
+	if bit < 0 || bit >= 64 {
+		return false
+	}
+	return b&(1<<uint(bit)) != 0
+}
+
+// amsPresent reports whether the AMS unit with the given ID is flagged in ams_exist_bits.
+func (b amsBits) amsPresent(amsID int) bool {
+	if amsID >= amsHTFirstID {
+		return b.has(amsHTBitBase + amsID - amsHTFirstID)
+	}
+	return b.has(amsID)
+}
+
+// tray reports whether the bit for the given tray is set in one of the per-tray bitmasks.
+func (b amsBits) tray(amsID, trayID int) bool {
+	return b.has(trayBit(amsID, trayID))
+}
+
+// trayBit returns the bit index of a tray within the per-tray bitmasks.
+func trayBit(amsID, trayID int) int {
+	if amsID >= amsHTFirstID {
+		return amsHTBitBase + amsID - amsHTFirstID
+	}
+	if amsID < 0 || amsID >= maxAmsUnits || trayID < 0 || trayID >= traysPerAms {
+		return -1
+	}
+	return amsID*traysPerAms + trayID
+}
+
+// parseTrayLocation converts a tray_now/tray_tar value into a TrayLocation, returning
+// nil when no tray is selected.
+func parseTrayLocation(s string) *TrayLocation {
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case v == trayNowNone:
+		return nil
+	case v == trayNowExternal:
+		return &TrayLocation{AmsID: -1, TrayID: 0}
+	case v >= amsHTFirstID && v < amsHTFirstID+maxAmsHT:
+		return &TrayLocation{AmsID: v, TrayID: 0}
+	case v >= 0 && v < maxAmsUnits*traysPerAms:
+		return &TrayLocation{AmsID: v / traysPerAms, TrayID: v % traysPerAms}
+	default:
+		return nil
+	}
+}
+
+// parseAmsType decodes the unit type from the "info" field of an AMS entry, falling
+// back to the ID range when the field is missing.
+func parseAmsType(info string, amsID int) AmsType {
+	if info != "" {
+		if v, err := strconv.ParseUint(info, 16, 64); err == nil {
+			switch v & 0xF {
+			case 1:
+				return AmsTypeAms
+			case 2:
+				return AmsTypeAmsLite
+			case 3:
+				return AmsTypeAms2Pro
+			case 4:
+				return AmsTypeAmsHT
+			}
+		}
+	}
+
+	if amsID >= amsHTFirstID {
+		return AmsTypeAmsHT
+	}
+	return AmsTypeUnknown
+}
@@ -0,0 +1,53 @@
+package bambulabs_cloud_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAmsBits(t *testing.T) {
+	assert.Equal(t, amsBits(0), parseAmsBits(""))
+	assert.Equal(t, amsBits(0), parseAmsBits("zz"))
+	assert.Equal(t, amsBits(1), parseAmsBits("1"))
+	assert.Equal(t, amsBits(0xF00F), parseAmsBits("f00f"))
+}
+
+func TestAmsBits_Tray(t *testing.T) {
+	// Two AMS units: unit 0 has trays 0 and 3 loaded, unit 1 has tray 1 loaded.
+	bits := parseAmsBits("29")
+	assert.True(t, bits.tray(0, 0))
+	assert.False(t, bits.tray(0, 1))
+	assert.True(t, bits.tray(0, 3))
+	assert.True(t, bits.tray(1, 1))
+	assert.False(t, bits.tray(1, 0))
+	assert.False(t, bits.tray(4, 0))
+
+	exist := parseAmsBits("3")
+	assert.True(t, exist.amsPresent(0))
+	assert.True(t, exist.amsPresent(1))
+	assert.False(t, exist.amsPresent(2))
+
+	ht := parseAmsBits("10000")
+	assert.True(t, ht.amsPresent(128))
+	assert.True(t, ht.tray(128, 0))
+	assert.False(t, ht.tray(129, 0))
+}
+
+func TestParseTrayLocation(t *testing.T) {
+	assert.Nil(t, parseTrayLocation(""))
+	assert.Nil(t, parseTrayLocation("255"))
+	assert.Equal(t, &TrayLocation{AmsID: -1, TrayID: 0}, parseTrayLocation("254"))
+	assert.True(t, parseTrayLocation("254").External())
+	assert.Equal(t, &TrayLocation{AmsID: 0, TrayID: 2}, parseTrayLocation("2"))
+	assert.Equal(t, &TrayLocation{AmsID: 3, TrayID: 3}, parseTrayLocation("15"))
+	assert.Equal(t, &TrayLocation{AmsID: 129, TrayID: 0}, parseTrayLocation("129"))
+	assert.Nil(t, parseTrayLocation("16"))
+}
+
+func TestParseAmsType(t *testing.T) {
+	assert.Equal(t, AmsTypeAmsLite, parseAmsType("1002", 0))
+	assert.Equal(t, AmsTypeAms, parseAmsType("2001", 1))
+	assert.Equal(t, AmsTypeAmsHT, parseAmsType("", 128))
+	assert.Equal(t, AmsTypeUnknown, parseAmsType("", 0))
+}
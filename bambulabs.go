@@ -1,31 +1,164 @@
 package bambulabs_cloud_api
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/ftp"
 	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
 	"github.com/torbenconto/bambulabs_cloud_api/state"
 	"image/color"
+	"slices"
 	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	localMqttPort     = 8883
+	localMqttUsername = "bblp"
+	localMqttTimeout  = 10 * time.Second
 )
 
 type Printer struct {
 	mqttClient *mqtt.Client
 	serial     string
+
+	// Set for printers reached over the LAN, which own their MQTT client rather than
+	// sharing the one of a PrinterPool.
+	host       string
+	accessCode string
+	local      bool
+
+	mu     sync.Mutex
+	device Device
+	files  *ftp.Client
+	tags   []string
 }
 
 func NewPrinter(config *PrinterConfig) *Printer {
-	return &Printer{
+	printer := &Printer{
 		mqttClient: config.MqttClient,
 		serial:     config.SerialNumber,
+		device:     config.Device,
+	}
+	printer.AddTags(config.Tags...)
+	return printer
+}
+
+// NewLocalPrinter returns a printer reached directly over the LAN through the MQTT
+// broker running on the printer itself, without going through the cloud. The access
+// code is shown on the printer's screen.
+//
+// The printer's certificate is verified against the bundled Bambu Lab CA and must be
+// issued to serial; use NewLocalPrinterWithConfig to change the TLS settings.
+func NewLocalPrinter(ip, serial, accessCode string) *Printer {
+	return NewLocalPrinterWithConfig(&mqtt.ClientConfig{
+		Host:       ip,
+		Serials:    []string{serial},
+		AccessCode: accessCode,
+	})
+}
+
+// NewLocalPrinterWithConfig is like NewLocalPrinter but takes the full client
+// configuration, with Host set to the printer's IP and Serials to its serial. Port,
+// Username, Timeout and ServerSerial default to the values of the printer's broker.
+// The configuration is copied, the caller's is left untouched.
+func NewLocalPrinterWithConfig(config *mqtt.ClientConfig) *Printer {
+	copied := *config
+	copied.Serials = slices.Clone(config.Serials)
+	config = &copied
+
+	var serial string
+	if len(config.Serials) > 0 {
+		serial = config.Serials[0]
+	}
+
+	if config.Port == 0 {
+		config.Port = localMqttPort
+	}
+	if config.Username == "" {
+		config.Username = localMqttUsername
+	}
+	if config.Timeout == 0 {
+		config.Timeout = localMqttTimeout
+	}
+	if config.ServerSerial == "" {
+		config.ServerSerial = serial
+	}
+
+	return &Printer{
+		mqttClient: mqtt.NewClient(config),
+		serial:     serial,
+		host:       config.Host,
+		accessCode: config.AccessCode,
+		local:      true,
 	}
 }
 
+// Connect connects a LAN printer to its broker. Printers from a PrinterPool share
+// the pool's connection, which is managed by PrinterPool.ConnectAll.
 func (p *Printer) Connect() error {
-	return nil
+	if !p.local {
+		return nil
+	}
+	return p.mqttClient.Connect()
 }
 
+// Disconnect disconnects a LAN printer from its broker. Printers from a PrinterPool
+// are disconnected by PrinterPool.DisconnectAll.
 func (p *Printer) Disconnect() {
+	if !p.local {
+		return
+	}
 	p.mqttClient.Disconnect()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.files != nil {
+		p.files.Close()
+		p.files = nil
+	}
+}
+
+// Serial returns the serial number of the printer.
+func (p *Printer) Serial() string {
+	return p.serial
+}
+
+// Device returns the cloud metadata of the printer, zero for printers that were not
+// listed by the cloud API. It is kept up to date by PrinterPool.Sync.
+func (p *Printer) Device() Device {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.device
+}
+
+// setDevice replaces the cloud metadata of the printer, reporting whether the
+// name, online or print status changed.
+func (p *Printer) setDevice(device Device) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := p.device.Name != device.Name || p.device.Online != device.Online || p.device.PrintStatus != device.PrintStatus
+	p.device = device
+	return changed
+}
+
+// IsLocal reports whether the printer is reached over the LAN rather than the cloud.
+func (p *Printer) IsLocal() bool {
+	return p.local
+}
+
+// RawData returns the merged raw JSON reports of the printer, including fields that
+// are not decoded into Data yet.
+func (p *Printer) RawData() json.RawMessage {
+	return p.mqttClient.RawData(p.serial)
+}
+
+// RawValue decodes the value at path in the printer's raw reports into T, see
+// mqtt.Lookup for the path syntax.
+func RawValue[T any](p *Printer, path string) (T, error) {
+	return mqtt.Lookup[T](p.RawData(), path)
 }
 
 func unsafeParseFloat(s string) float64 {
@@ -41,9 +174,17 @@ func unsafeParseInt(s string) int {
 func (p *Printer) Data() (Data, error) {
 	data := p.mqttClient.Data(p.serial)
 
+	amsExist := parseAmsBits(data.Print.Ams.AmsExistBits)
+	trayExist := parseAmsBits(data.Print.Ams.TrayExistBits)
+	trayIsBbl := parseAmsBits(data.Print.Ams.TrayIsBblBits)
+	trayReadDone := parseAmsBits(data.Print.Ams.TrayReadDoneBits)
+	trayReading := parseAmsBits(data.Print.Ams.TrayReadingBits)
+
 	final := Data{
 		Ams:                     make([]Ams, 0),
-		AmsExists:               data.Print.Ams.AmsExistBits == "1",
+		AmsExists:               amsExist != 0,
+		ActiveTray:              parseTrayLocation(data.Print.Ams.TrayNow),
+		TargetTray:              parseTrayLocation(data.Print.Ams.TrayTar),
 		BedTargetTemperature:    data.Print.BedTargetTemper,
 		BedTemperature:          data.Print.BedTemper,
 		AuxiliaryFanSpeed:       unsafeParseInt(data.Print.BigFan1Speed),
@@ -58,8 +199,10 @@ func (p *Printer) Data() (Data, error) {
 		PrintErrorCode:          data.Print.McPrintErrorCode,
 		RemainingPrintTime:      data.Print.McRemainingTime,
 		SubtaskName:             data.Print.SubtaskName,
+		SkippedObjects:          data.Print.SObj,
 		SubtaskID:               unsafeParseInt(data.Print.SubtaskID),
 		TaskID:                  unsafeParseInt(data.Print.TaskID),
+		LayerNumber:             data.Print.LayerNum,
 		TotalLayerNumber:        data.Print.TotalLayerNum,
 		NozzleDiameter:          data.Print.NozzleDiameter,
 		NozzleTargetTemperature: data.Print.NozzleTargetTemper,
@@ -68,6 +211,18 @@ func (p *Printer) Data() (Data, error) {
 		WifiSignal:              data.Print.WifiSignal,
 	}
 
+	final.Camera = cameraState(data)
+
+	final.Xcam = XcamSettings{
+		SpaghettiDetector:        data.Print.Xcam.SpaghettiDetector,
+		FirstLayerInspector:      data.Print.Xcam.FirstLayerInspector,
+		BuildplateMarkerDetector: data.Print.Xcam.BuildplateMarkerDetector,
+		PrintingMonitor:          data.Print.Xcam.PrintingMonitor,
+		AllowSkipParts:           data.Print.Xcam.AllowSkipParts,
+		PrintHalt:                data.Print.Xcam.PrintHalt,
+		HaltSensitivity:          HaltSensitivity(data.Print.Xcam.HaltPrintSensitivity),
+	}
+
 	colors := make([]color.RGBA, 0)
 	for _, col := range data.Print.VtTray.Cols {
 		if col == "" {
@@ -103,12 +258,16 @@ func (p *Printer) Data() (Data, error) {
 		TraySubBrands:     data.Print.VtTray.TraySubBrands,
 		TrayType:          data.Print.VtTray.TrayType,
 		TrayWeight:        unsafeParseInt(data.Print.VtTray.TrayWeight),
+		RemainingPercent:  remainingPercent(data.Print.VtTray.Remain),
+		RemainingGrams:    remainingGrams(data.Print.VtTray.Remain, unsafeParseInt(data.Print.VtTray.TrayWeight)),
 	}
 
 	for _, ams := range data.Print.Ams.Ams {
+		amsID := unsafeParseInt(ams.ID)
 		trays := make([]Tray, 0)
 
 		for _, tray := range ams.Tray {
+			trayID := unsafeParseInt(tray.ID)
 			colors := make([]color.RGBA, 0)
 
 			for _, col := range tray.Cols {
@@ -132,7 +291,7 @@ func (p *Printer) Data() (Data, error) {
 			}
 
 			trays = append(trays, Tray{
-				ID:                unsafeParseInt(tray.ID),
+				ID:                trayID,
 				BedTemperature:    unsafeParseFloat(tray.BedTemp),
 				Colors:            colors,
 				DryingTemperature: unsafeParseFloat(tray.DryingTemp),
@@ -144,12 +303,20 @@ func (p *Printer) Data() (Data, error) {
 				TraySubBrands:     tray.TraySubBrands,
 				TrayType:          tray.TrayType,
 				TrayWeight:        unsafeParseInt(tray.TrayWeight),
+				RemainingPercent:  remainingPercent(tray.Remain),
+				RemainingGrams:    remainingGrams(tray.Remain, unsafeParseInt(tray.TrayWeight)),
+				Present:           trayExist.tray(amsID, trayID),
+				IsBambuSpool:      trayIsBbl.tray(amsID, trayID),
+				RFIDRead:          trayReadDone.tray(amsID, trayID),
+				RFIDReading:       trayReading.tray(amsID, trayID),
 			})
 		}
 
 		final.Ams = append(final.Ams, Ams{
 			Humidity:    unsafeParseInt(ams.Humidity),
-			ID:          unsafeParseInt(ams.ID),
+			ID:          amsID,
+			Type:        parseAmsType(ams.Info, amsID),
+			Present:     amsExist.amsPresent(amsID),
 			Temperature: unsafeParseFloat(ams.Temp),
 			Trays:       trays,
 		})
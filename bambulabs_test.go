@@ -0,0 +1,19 @@
+package bambulabs_cloud_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+func TestNewLocalPrinterWithConfig(t *testing.T) {
+	config := &mqtt.ClientConfig{Host: "192.168.1.20", Serials: []string{"01P00A000000000"}, AccessCode: "12345678"}
+	printer := NewLocalPrinterWithConfig(config)
+
+	assert.Equal(t, "01P00A000000000", printer.Serial())
+	assert.True(t, printer.IsLocal())
+
+	// Defaults are applied to a copy.
+	assert.Equal(t, &mqtt.ClientConfig{Host: "192.168.1.20", Serials: []string{"01P00A000000000"}, AccessCode: "12345678"}, config)
+}
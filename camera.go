@@ -0,0 +1,240 @@
+package bambulabs_cloud_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/camera"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+var (
+	// ErrNotSupported is returned by features the printer model does not have.
+	ErrNotSupported = errors.New("not supported by this printer")
+
+	// ErrCameraDisabled is returned when the camera or its LAN liveview is turned off.
+	ErrCameraDisabled = errors.New("camera is disabled")
+)
+
+type CameraResolution string
+
+const (
+	CameraResolution720p  CameraResolution = "720p"
+	CameraResolution1080p CameraResolution = "1080p"
+)
+
+type Camera struct {
+	Enabled    bool             `json:"enabled"`    // Whether the camera is on
+	Recording  bool             `json:"recording"`  // Whether prints are recorded
+	Timelapse  bool             `json:"timelapse"`  // Whether timelapses are recorded
+	Resolution CameraResolution `json:"resolution"` // Resolution of the camera
+	Liveview   bool             `json:"liveview"`   // Whether the RTSPS stream is enabled (X1 series)
+}
+
+// StreamURL returns the RTSPS URL of the camera of an X1 series printer on the LAN,
+// including the credentials. The LAN liveview must be enabled, see SetLiveview.
+func (p *Printer) StreamURL() (string, error) {
+	if !p.local {
+		return "", ErrNotLocal
+	}
+	if !p.Model().IsX1() {
+		return "", ErrNotSupported
+	}
+
+	ipcam := p.mqttClient.Data(p.serial).Print.Ipcam
+	if ipcam.IpcamDev != "1" || ipcam.RtspURL == "disable" {
+		return "", ErrCameraDisabled
+	}
+
+	stream := url.URL{
+		Scheme: "rtsps",
+		User:   url.UserPassword(localMqttUsername, p.accessCode),
+		Host:   net.JoinHostPort(p.host, strconv.Itoa(camera.DefaultRTSPPort)),
+		Path:   "/streaming/live/1",
+	}
+	return stream.String(), nil
+}
+
+// SetLiveview turns the RTSPS stream of the camera of an X1 series printer on the
+// LAN on or off.
+func (p *Printer) SetLiveview(ctx context.Context, enabled bool) error {
+	return p.cameraCommand(ctx, liveviewCommand(enabled), "control")
+}
+
+// SetTimelapse turns the recording of timelapses of the following prints on or off.
+func (p *Printer) SetTimelapse(ctx context.Context, enabled bool) error {
+	return p.cameraCommand(ctx, timelapseCommand(enabled), "control")
+}
+
+// SetRecording turns the recording of prints on or off.
+func (p *Printer) SetRecording(ctx context.Context, enabled bool) error {
+	return p.cameraCommand(ctx, recordingCommand(enabled), "control")
+}
+
+// SetCameraResolution sets the resolution of the camera.
+func (p *Printer) SetCameraResolution(ctx context.Context, resolution CameraResolution) error {
+	return p.cameraCommand(ctx, resolutionCommand(resolution), "resolution")
+}
+
+// cameraCommand sends a camera command and checks that the reply acknowledges the
+// value of field.
+func (p *Printer) cameraCommand(ctx context.Context, command *mqtt.Command, field string) error {
+	name, _ := command.Field("command").(string)
+	raw, err := p.mqttClient.Request(ctx, p.serial, command)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return checkCameraReply(raw, name, field, command.Field(field))
+}
+
+func liveviewCommand(enabled bool) *mqtt.Command {
+	return mqtt.NewCommand(mqtt.Camera).
+		AddCommandField("ipcam_rtsp_set").
+		AddField("control", enableString(enabled))
+}
+
+func timelapseCommand(enabled bool) *mqtt.Command {
+	return mqtt.NewCommand(mqtt.Camera).
+		AddCommandField("ipcam_timelapse").
+		AddField("control", enableString(enabled))
+}
+
+func recordingCommand(enabled bool) *mqtt.Command {
+	return mqtt.NewCommand(mqtt.Camera).
+		AddCommandField("ipcam_record_set").
+		AddField("control", enableString(enabled))
+}
+
+func resolutionCommand(resolution CameraResolution) *mqtt.Command {
+	return mqtt.NewCommand(mqtt.Camera).
+		AddCommandField("ipcam_resolution_set").
+		AddField("resolution", string(resolution))
+}
+
+// checkCameraReply returns an error unless the reply acknowledges the requested
+// value of field, when it echoes it.
+func checkCameraReply(raw json.RawMessage, name, field string, want any) error {
+	var reply map[string]any
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return fmt.Errorf("%s: invalid reply: %w", name, err)
+	}
+	if got, ok := reply[field]; ok && got != want {
+		return fmt.Errorf("%s: printer kept %s %v", name, field, got)
+	}
+	return nil
+}
+
+// cameraState returns the camera settings reported by the printer.
+func cameraState(data mqtt.Message) Camera {
+	ipcam := data.Print.Ipcam
+	return Camera{
+		Enabled:    ipcam.IpcamDev == "1",
+		Recording:  ipcam.IpcamRecord == "enable",
+		Timelapse:  ipcam.Timelapse == "enable",
+		Resolution: CameraResolution(ipcam.Resolution),
+		Liveview:   ipcam.RtspURL != "" && ipcam.RtspURL != "disable",
+	}
+}
+
+// MJPEGHandler returns an http.Handler relaying the camera of a printer on the LAN
+// as MJPEG, see camera.MJPEGHandler. It responds with 503 Service Unavailable when
+// the stream ends before the first frame.
+//
+// X1 series printers stream H.264 over RTSPS, see StreamURL, which is decoded by
+// the decoder registered with camera.RegisterH264Decoder and re-encoded as JPEG;
+// camera.ErrNoDecoder is returned when there is none.
+func (p *Printer) MJPEGHandler() (http.Handler, error) {
+	client, err := p.camera()
+	if err != nil {
+		return nil, err
+	}
+	return camera.NewMJPEGHandler(client.Stream), nil
+}
+
+func enableString(enabled bool) string {
+	if enabled {
+		return "enable"
+	}
+	return "disable"
+}
+
+// Snapshot returns the current image of the camera of a printer on the LAN. X1
+// series printers need an H.264 decoder, see MJPEGHandler.
+func (p *Printer) Snapshot(ctx context.Context) (image.Image, error) {
+	frame, err := p.SnapshotFrame(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFrame(frame)
+}
+
+// SnapshotFrame is like Snapshot but returns the frame as sent by the camera, the
+// image still JPEG encoded.
+func (p *Printer) SnapshotFrame(ctx context.Context) (camera.Frame, error) {
+	client, err := p.camera()
+	if err != nil {
+		return camera.Frame{}, err
+	}
+	return client.Snapshot(ctx)
+}
+
+// StreamFrames streams the camera of a printer on the LAN until ctx is done, see
+// camera.Client.Stream and camera.RTSPClient.Stream. X1 series printers need an
+// H.264 decoder, see MJPEGHandler.
+func (p *Printer) StreamFrames(ctx context.Context) (<-chan camera.Frame, error) {
+	client, err := p.camera()
+	if err != nil {
+		return nil, err
+	}
+	return client.Stream(ctx), nil
+}
+
+func decodeFrame(frame camera.Frame) (image.Image, error) {
+	img, err := jpeg.Decode(bytes.NewReader(frame.Image))
+	if err != nil {
+		return nil, fmt.Errorf("decoding camera frame: %w", err)
+	}
+	return img, nil
+}
+
+// cameraClient reads the camera of a printer: camera.Client for the P1 and A1
+// series, camera.RTSPClient for the X1 series.
+type cameraClient interface {
+	Snapshot(ctx context.Context) (camera.Frame, error)
+	Stream(ctx context.Context) <-chan camera.Frame
+}
+
+func (p *Printer) camera() (cameraClient, error) {
+	if !p.local {
+		return nil, ErrNotLocal
+	}
+	if p.Model().IsX1() {
+		if !camera.HasH264Decoder() {
+			return nil, camera.ErrNoDecoder
+		}
+		stream, err := p.StreamURL()
+		if err != nil {
+			return nil, err
+		}
+		return camera.NewRTSPClient(&camera.RTSPConfig{
+			URL:       stream,
+			TLSConfig: p.mqttClient.TLSConfig(),
+		}), nil
+	}
+
+	return camera.NewClient(&camera.ClientConfig{
+		Host:       p.host,
+		Username:   localMqttUsername,
+		AccessCode: p.accessCode,
+		TLSConfig:  p.mqttClient.TLSConfig(),
+	}), nil
+}
@@ -0,0 +1,141 @@
+package bambulabs_cloud_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/camera"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+func TestPrinter_CameraX1(t *testing.T) {
+	x1 := NewLocalPrinter("192.168.1.20", "00M00A000000000", "12345678")
+
+	// X1 series printers stream H.264, which needs a registered decoder.
+	_, err := x1.Snapshot(context.Background())
+	assert.ErrorIs(t, err, camera.ErrNoDecoder)
+
+	_, err = x1.SnapshotFrame(context.Background())
+	assert.ErrorIs(t, err, camera.ErrNoDecoder)
+
+	frames, err := x1.StreamFrames(context.Background())
+	assert.ErrorIs(t, err, camera.ErrNoDecoder)
+	assert.Nil(t, frames)
+
+	// With one, the LAN liveview must be enabled, see StreamURL.
+	camera.RegisterH264Decoder(func() (camera.H264Decoder, error) { return nil, nil })
+	defer camera.RegisterH264Decoder(nil)
+	_, err = x1.Snapshot(context.Background())
+	assert.ErrorIs(t, err, ErrCameraDisabled)
+
+	_, err = NewPrinter(&PrinterConfig{SerialNumber: "01P00A000000000"}).StreamFrames(context.Background())
+	assert.ErrorIs(t, err, ErrNotLocal)
+}
+
+func TestDecodeFrame(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 16, 9)), nil))
+
+	img, err := decodeFrame(camera.Frame{Image: encoded.Bytes()})
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 16, 9), img.Bounds())
+
+	_, err = decodeFrame(camera.Frame{Image: []byte{0xff, 0xd8, 0xff, 0xd9}})
+	assert.Error(t, err)
+}
+
+func TestPrinter_StreamURL(t *testing.T) {
+	_, err := NewLocalPrinter("192.168.1.20", "01P00A000000000", "12345678").StreamURL()
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	_, err = NewPrinter(&PrinterConfig{SerialNumber: "00M00A000000000"}).StreamURL()
+	assert.ErrorIs(t, err, ErrNotLocal)
+
+	// No report received yet, so the camera is not known to be enabled.
+	_, err = NewLocalPrinter("192.168.1.20", "00M00A000000000", "12345678").StreamURL()
+	assert.ErrorIs(t, err, ErrCameraDisabled)
+}
+
+func TestPrinter_MJPEGHandler(t *testing.T) {
+	// X1 series printers stream H.264 over RTSPS, see TestPrinter_CameraX1.
+	_, err := NewLocalPrinter("192.168.1.20", "00M00A000000000", "12345678").MJPEGHandler()
+	assert.ErrorIs(t, err, camera.ErrNoDecoder)
+
+	_, err = NewPrinter(&PrinterConfig{SerialNumber: "01P00A000000000"}).MJPEGHandler()
+	assert.ErrorIs(t, err, ErrNotLocal)
+
+	handler, err := NewLocalPrinter("192.168.1.20", "01P00A000000000", "12345678").MJPEGHandler()
+	require.NoError(t, err)
+	assert.NotNil(t, handler)
+}
+
+func TestCameraCommands(t *testing.T) {
+	liveview := commandFields(t, liveviewCommand(true))
+	assert.Equal(t, "ipcam_rtsp_set", liveview["command"])
+	assert.Equal(t, "enable", liveview["control"])
+
+	timelapse := commandFields(t, timelapseCommand(false))
+	assert.Equal(t, "ipcam_timelapse", timelapse["command"])
+	assert.Equal(t, "disable", timelapse["control"])
+
+	recording := commandFields(t, recordingCommand(true))
+	assert.Equal(t, "ipcam_record_set", recording["command"])
+	assert.Equal(t, "enable", recording["control"])
+
+	resolution := commandFields(t, resolutionCommand(CameraResolution1080p))
+	assert.Equal(t, "ipcam_resolution_set", resolution["command"])
+	assert.Equal(t, "1080p", resolution["resolution"])
+}
+
+func TestCheckCameraReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		field   string
+		want    string
+		wantErr bool
+	}{
+		{name: "acknowledged", reply: `{"command":"ipcam_timelapse","control":"enable","result":"success"}`, field: "control", want: "enable"},
+		{name: "without echo", reply: `{"command":"ipcam_timelapse","result":"success"}`, field: "control", want: "enable"},
+		{name: "not applied", reply: `{"command":"ipcam_record_set","control":"disable"}`, field: "control", want: "enable", wantErr: true},
+		{name: "resolution", reply: `{"command":"ipcam_resolution_set","resolution":"720p"}`, field: "resolution", want: "1080p", wantErr: true},
+		{name: "invalid", reply: `[]`, field: "control", want: "enable", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCameraReply([]byte(tt.reply), "command", tt.field, tt.want)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCameraState(t *testing.T) {
+	var data mqtt.Message
+	require.NoError(t, json.Unmarshal([]byte(`{"print":{"ipcam":{"ipcam_dev":"1","ipcam_record":"enable","timelapse":"disable","resolution":"1080p","rtsp_url":"rtsps://192.168.1.20/streaming/live/1"}}}`), &data))
+
+	assert.Equal(t, Camera{
+		Enabled:    true,
+		Recording:  true,
+		Timelapse:  false,
+		Resolution: CameraResolution1080p,
+		Liveview:   true,
+	}, cameraState(data))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"print":{"ipcam":{"ipcam_dev":"0","ipcam_record":"disable","timelapse":"enable","rtsp_url":"disable"}}}`), &data))
+	state := cameraState(data)
+	assert.False(t, state.Enabled)
+	assert.False(t, state.Recording)
+	assert.True(t, state.Timelapse)
+	assert.False(t, state.Liveview)
+}
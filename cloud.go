@@ -292,46 +292,53 @@ func (c *Client) GetPrintersAsPool() (*PrinterPool, error) {
 
 	pool := NewPrinterPool(mqttConfig)
 	for _, device := range printersResp.Devices {
-		pool.AddPrinter(&PrinterConfig{
+		if err := pool.AddPrinter(&PrinterConfig{
 			MqttClient:   pool.mqttClient,
 			SerialNumber: device.DevID,
-		})
+			Device:       device,
+		}); err != nil {
+			return &PrinterPool{}, err
+		}
 	}
 
 	return pool, nil
 }
 
+type AMSDetailMapping struct {
+	AMS                int     `json:"ams"`
+	SourceColor        string  `json:"sourceColor"`
+	TargetColor        string  `json:"targetColor"`
+	FilamentID         string  `json:"filamentId"`
+	FilamentType       string  `json:"filamentType"`
+	TargetFilamentType string  `json:"targetFilamentType"`
+	Weight             float64 `json:"weight"`
+	NozzleID           int     `json:"nozzleId"`
+	AMSID              int     `json:"amsId"`
+	SlotID             int     `json:"slotId"`
+}
+
+type Task struct {
+	ID               int                `json:"id"`
+	DesignID         int                `json:"designId"`
+	ModelID          string             `json:"modelId"`
+	Title            string             `json:"title"`
+	Cover            string             `json:"cover"`
+	Status           int                `json:"status"`
+	Weight           float64            `json:"weight"`
+	Length           float64            `json:"length"`
+	CostTime         int                `json:"costTime"`
+	ProfileID        int                `json:"profileId"`
+	PlateIndex       int                `json:"plateIndex"`
+	PlateName        string             `json:"plateName"`
+	DeviceID         string             `json:"deviceId"`
+	DeviceModel      string             `json:"deviceModel"`
+	DeviceName       string             `json:"deviceName"`
+	AMSDetailMapping []AMSDetailMapping `json:"amsDetailMapping"`
+}
+
 type GetTasksResponse struct {
-	Total int `json:"total"`
-	Hits  []struct {
-		ID               int    `json:"id"`
-		DesignID         int    `json:"designId"`
-		ModelID          string `json:"modelId"`
-		Title            string `json:"title"`
-		Cover            string `json:"cover"`
-		Status           int    `json:"status"`
-		Weight           float64    `json:"weight"`
-		Length           float64    `json:"length"`
-		CostTime         int    `json:"costTime"`
-		ProfileID        int    `json:"profileId"`
-		PlateIndex       int    `json:"plateIndex"`
-		PlateName        string `json:"plateName"`
-		DeviceID         string `json:"deviceId"`
-		DeviceModel      string `json:"deviceModel"`
-		DeviceName       string `json:"deviceName"`
-		AMSDetailMapping []struct {
-			AMS                int    `json:"ams"`
-			SourceColor        string `json:"sourceColor"`
-			TargetColor        string `json:"targetColor"`
-			FilamentID         string `json:"filamentId"`
-			FilamentType       string `json:"filamentType"`
-			TargetFilamentType string `json:"targetFilamentType"`
-			Weight             float64    `json:"weight"`
-			NozzleID           int    `json:"nozzleId"`
-			AMSID              int    `json:"amsId"`
-			SlotID             int    `json:"slotId"`
-		} `json:"amsDetailMapping"`
-	} `json:"hits"`
+	Total int    `json:"total"`
+	Hits  []Task `json:"hits"`
 }
 
 func (c *Client) GetTasks(serial string) (*GetTasksResponse, error) {
@@ -11,4 +11,6 @@ type Config struct {
 type PrinterConfig struct {
 	MqttClient   *mqtt.Client
 	SerialNumber string
+	Device       Device   // Cloud metadata of the printer, if known
+	Tags         []string // Labels used to select the printer in a pool, see PrinterPool.Select
 }
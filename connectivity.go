@@ -0,0 +1,114 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"time"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+const (
+	// DefaultStaleThreshold is the time without reports after which a printer is
+	// considered offline when no threshold is given. An idle printer may stay silent
+	// until the client requests a full report after mqtt.DefaultPushAllInterval, so
+	// the threshold leaves it a minute to answer.
+	DefaultStaleThreshold = mqtt.DefaultPushAllInterval + time.Minute
+	// DefaultConnectivityInterval is the interval at which WatchConnectivity checks
+	// the printers when none is given.
+	DefaultConnectivityInterval = 5 * time.Second
+)
+
+// LastSeen returns the time the last report of the printer was received, zero if
+// none has been received since the client connected.
+func (p *Printer) LastSeen() time.Time {
+	return p.mqttClient.LastSeen(p.serial)
+}
+
+// ConnectionState returns the state of the connection to the MQTT broker the
+// printer's reports are received from, shared by the printers of a cloud pool.
+func (p *Printer) ConnectionState() mqtt.ConnectionState {
+	return p.mqttClient.State()
+}
+
+// IsStale reports whether the printer has not sent a report for longer than
+// threshold, or has not sent any. Data then reflects the last known state, which
+// may be outdated.
+func (p *Printer) IsStale(threshold time.Duration) bool {
+	lastSeen := p.LastSeen()
+	return lastSeen.IsZero() || time.Since(lastSeen) > threshold
+}
+
+// IsOnline reports whether the printer is online: it sent a report within threshold
+// (DefaultStaleThreshold if not positive) or, until the first report arrives, the
+// cloud reports it as online. A printer the cloud reports as offline is offline
+// whatever its reports.
+func (p *Printer) IsOnline(threshold time.Duration) bool {
+	return isOnline(p.LastSeen(), p.Device(), threshold, time.Now())
+}
+
+func isOnline(lastSeen time.Time, device Device, threshold time.Duration, now time.Time) bool {
+	if threshold <= 0 {
+		threshold = DefaultStaleThreshold
+	}
+	if lastSeen.IsZero() {
+		return device.Online
+	}
+	// Printers without cloud metadata, such as LAN printers, only have reports.
+	if device.DevID != "" && !device.Online {
+		return false
+	}
+	return now.Sub(lastSeen) <= threshold
+}
+
+// ConnectivityEvent reports that a printer went online or offline.
+type ConnectivityEvent struct {
+	Serial   string
+	Online   bool
+	LastSeen time.Time // Time of the last report, zero if none was received
+}
+
+// WatchConnectivity checks the printers of the pool at every interval
+// (DefaultConnectivityInterval if not positive) until ctx is done, and sends an
+// event on the returned channel whenever one goes online or offline, see
+// Printer.IsOnline. The first check of a printer only records its state. The channel
+// is closed once ctx is done.
+func (p *PrinterPool) WatchConnectivity(ctx context.Context, threshold, interval time.Duration) <-chan ConnectivityEvent {
+	if interval <= 0 {
+		interval = DefaultConnectivityInterval
+	}
+	events := make(chan ConnectivityEvent, 16)
+
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		online := make(map[string]bool)
+		for {
+			current := make(map[string]bool)
+			for _, printer := range p.GetPrinters() {
+				state := printer.IsOnline(threshold)
+				current[printer.serial] = state
+
+				previous, known := online[printer.serial]
+				if !known || previous == state {
+					continue
+				}
+				select {
+				case events <- ConnectivityEvent{Serial: printer.serial, Online: state, LastSeen: printer.LastSeen()}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			online = current
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events
+}
@@ -0,0 +1,64 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+func TestIsOnline(t *testing.T) {
+	now := time.Now()
+
+	assert.True(t, isOnline(now.Add(-10*time.Second), Device{}, 30*time.Second, now))
+	assert.False(t, isOnline(now.Add(-time.Minute), Device{Online: true}, 30*time.Second, now), "stale reports win over the cloud status")
+	assert.True(t, isOnline(now.Add(-50*time.Second), Device{}, 0, now), "default threshold")
+	assert.True(t, isOnline(now.Add(-mqtt.DefaultPushAllInterval), Device{}, 0, now), "idle until the next pushall")
+	assert.False(t, isOnline(now.Add(-10*time.Second), Device{DevID: "00M00A000000001"}, 30*time.Second, now), "cloud offline status wins")
+	assert.True(t, isOnline(now.Add(-10*time.Second), Device{DevID: "00M00A000000001", Online: true}, 30*time.Second, now))
+	assert.True(t, isOnline(time.Time{}, Device{Online: true}, 30*time.Second, now))
+	assert.False(t, isOnline(time.Time{}, Device{}, 30*time.Second, now))
+}
+
+func TestPrinter_NeverSeen(t *testing.T) {
+	printer := NewPrinter(&PrinterConfig{MqttClient: mqtt.NewClient(&mqtt.ClientConfig{}), SerialNumber: "01P00A000000000"})
+
+	assert.True(t, printer.LastSeen().IsZero())
+	assert.True(t, printer.IsStale(time.Hour))
+	assert.False(t, printer.IsOnline(time.Hour))
+}
+
+func TestPrinterPool_WatchConnectivity(t *testing.T) {
+	pool := NewPrinterPool(&mqtt.ClientConfig{})
+	require.NoError(t, pool.AddPrinter(&PrinterConfig{SerialNumber: "00M00A000000001", Device: Device{Online: true}}))
+	printer, _ := pool.GetPrinter("00M00A000000001")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := pool.WatchConnectivity(ctx, time.Minute, time.Millisecond)
+
+	receive := func() ConnectivityEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return ConnectivityEvent{}
+		}
+	}
+
+	// Let the first check record the initial state.
+	time.Sleep(100 * time.Millisecond)
+	printer.setDevice(Device{Online: false})
+	assert.Equal(t, ConnectivityEvent{Serial: "00M00A000000001", Online: false}, receive())
+
+	printer.setDevice(Device{Online: true})
+	assert.Equal(t, ConnectivityEvent{Serial: "00M00A000000001", Online: true}, receive())
+
+	cancel()
+	for range events {
+	}
+}
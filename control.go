@@ -0,0 +1,88 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/torbenconto/bambulabs_cloud_api/light"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+// Pause pauses the current print.
+func (p *Printer) Pause(ctx context.Context) error {
+	if _, err := p.mqttClient.Request(ctx, p.serial, printControlCommand("pause")); err != nil {
+		return fmt.Errorf("pause failed: %w", err)
+	}
+	return nil
+}
+
+// Resume resumes a paused print.
+func (p *Printer) Resume(ctx context.Context) error {
+	if _, err := p.mqttClient.Request(ctx, p.serial, printControlCommand("resume")); err != nil {
+		return fmt.Errorf("resume failed: %w", err)
+	}
+	return nil
+}
+
+// Refresh requests a full report from the printer and waits until it is received, so
+// that Data reflects its whole state rather than the deltas received so far.
+func (p *Printer) Refresh(ctx context.Context) error {
+	if err := p.mqttClient.Refresh(ctx, p.serial); err != nil {
+		return fmt.Errorf("pushall failed: %w", err)
+	}
+	return nil
+}
+
+// SetLight turns a light of the printer on or off.
+func (p *Printer) SetLight(ctx context.Context, l light.Light, on bool) error {
+	if _, err := p.mqttClient.Request(ctx, p.serial, lightCommand(l, on)); err != nil {
+		return fmt.Errorf("ledctrl failed: %w", err)
+	}
+	return nil
+}
+
+// SetBedTemperature sets the target temperature of the bed (°C), 0 to turn the
+// heater off.
+func (p *Printer) SetBedTemperature(ctx context.Context, celsius int) error {
+	return p.sendGcode(ctx, fmt.Sprintf("M140 S%d", celsius))
+}
+
+// SetNozzleTemperature sets the target temperature of the nozzle (°C), 0 to turn the
+// heater off.
+func (p *Printer) SetNozzleTemperature(ctx context.Context, celsius int) error {
+	return p.sendGcode(ctx, fmt.Sprintf("M104 S%d", celsius))
+}
+
+func (p *Printer) sendGcode(ctx context.Context, line string) error {
+	if _, err := p.mqttClient.Request(ctx, p.serial, gcodeLineCommand(line)); err != nil {
+		return fmt.Errorf("gcode_line failed: %w", err)
+	}
+	return nil
+}
+
+func printControlCommand(command string) *mqtt.Command {
+	return mqtt.NewCommand(mqtt.Print).
+		AddCommandField(command).
+		AddParamField("")
+}
+
+func lightCommand(l light.Light, on bool) *mqtt.Command {
+	mode := "off"
+	if on {
+		mode = "on"
+	}
+	return mqtt.NewCommand(mqtt.System).
+		AddCommandField("ledctrl").
+		AddField("led_node", string(l)).
+		AddField("led_mode", mode).
+		AddField("led_on_time", 500).
+		AddField("led_off_time", 500).
+		AddField("loop_times", 0).
+		AddField("interval_time", 0)
+}
+
+func gcodeLineCommand(line string) *mqtt.Command {
+	return mqtt.NewCommand(mqtt.Print).
+		AddCommandField("gcode_line").
+		AddParamField(line + "\n")
+}
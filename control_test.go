@@ -0,0 +1,34 @@
+package bambulabs_cloud_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/torbenconto/bambulabs_cloud_api/light"
+)
+
+func TestPrintControlCommand(t *testing.T) {
+	fields := commandFields(t, printControlCommand("pause"))
+
+	assert.Equal(t, "pause", fields["command"])
+	assert.Equal(t, "", fields["param"])
+}
+
+func TestLightCommand(t *testing.T) {
+	fields := commandFields(t, lightCommand(light.ChamberLight, false))
+
+	assert.Equal(t, "ledctrl", fields["command"])
+	assert.Equal(t, "chamber_light", fields["led_node"])
+	assert.Equal(t, "off", fields["led_mode"])
+
+	fields = commandFields(t, lightCommand(light.PartLight, true))
+	assert.Equal(t, "part_light", fields["led_node"])
+	assert.Equal(t, "on", fields["led_mode"])
+}
+
+func TestGcodeLineCommand(t *testing.T) {
+	fields := commandFields(t, gcodeLineCommand("M140 S60"))
+
+	assert.Equal(t, "gcode_line", fields["command"])
+	assert.Equal(t, "M140 S60\n", fields["param"])
+}
@@ -19,11 +19,19 @@ type Tray struct {
 	TraySubBrands     string       `json:"tray_sub_brands"`    // Detailed filament type (manual input or Bambu filament)
 	TrayType          string       `json:"tray_type"`          // Filament type (e.g., PLA, ABS, PLA-S)
 	TrayWeight        int          `json:"tray_weight"`        // Spool weight (grams, in intervals of 250g)
+	RemainingPercent  int          `json:"remaining_percent"`  // Filament left on the spool (%), -1 if unknown
+	RemainingGrams    float64      `json:"remaining_grams"`    // Estimated filament left on the spool (grams), -1 if unknown
+	Present           bool         `json:"present"`            // Whether a spool is loaded in the tray
+	IsBambuSpool      bool         `json:"is_bambu_spool"`     // Whether the spool was identified as Bambu filament
+	RFIDRead          bool         `json:"rfid_read"`          // Whether the RFID tag of the spool has been read
+	RFIDReading       bool         `json:"rfid_reading"`       // Whether the RFID tag of the spool is currently being read
 }
 
 type Ams struct {
 	Humidity    int     `json:"humidity"`    // 0-5: 0 is dry, 5 is wet
-	ID          int     `json:"id"`          // ID of the Ams object
+	ID          int     `json:"id"`          // ID of the Ams object (0-3, 128+ for AMS HT)
+	Type        AmsType `json:"type"`        // Kind of Ams unit (AMS, AMS Lite, AMS HT...)
+	Present     bool    `json:"present"`     // Whether the Ams unit is connected
 	Temperature float64 `json:"temperature"` // Temperature inside the Ams (°C)
 	Trays       []Tray  `json:"trays"`       // List of trays in the Ams
 }
@@ -31,6 +39,8 @@ type Ams struct {
 type Data struct {
 	Ams                     []Ams            `json:"ams"`                        // List of Ams objects
 	AmsExists               bool             `json:"ams_exists"`                 // Whether an Ams is connected
+	ActiveTray              *TrayLocation    `json:"active_tray"`                // Tray currently feeding the extruder, nil if none
+	TargetTray              *TrayLocation    `json:"target_tray"`                // Tray the printer is switching to, nil if none
 	BedTargetTemperature    float64          `json:"bed_target_temperature"`     // Target bed temperature (°C)
 	BedTemperature          float64          `json:"bed_temperature"`            // Current bed temperature (°C)
 	AuxiliaryFanSpeed       int              `json:"auxiliary_fan_speed"`        // Speed of the auxiliary fan (0-15)
@@ -46,15 +56,19 @@ type Data struct {
 	PrintErrorCode          string           `json:"print_error_code"`           // Current print error code
 	RemainingPrintTime      int              `json:"remaining_print_time"`       // Estimated remaining print time (minutes)
 	SubtaskName             string           `json:"subtask_name"`               // Name of the current print subtask
+	SkippedObjects          []int            `json:"skipped_objects"`            // IDs of the objects skipped in the current print
 	SubtaskID               int              `json:"subtask_id"`                 // ID of the current print subtask
 	TaskID                  int              `json:"task_id"`                    // ID of the current print task
 	ProjectID               string           `json:"project_id"`                 // ID of the current project
 	ProfileID               string           `json:"profile_id"`                 // ID of the current print profile
+	LayerNumber             int              `json:"layer_num"`                  // Layer being printed, from 1
 	TotalLayerNumber        int              `json:"total_layer_num"`            // Total number of layers in the print
 	NozzleDiameter          string           `json:"nozzle_diameter"`            // Diameter of the nozzle (mm)
 	NozzleTargetTemperature float64          `json:"nozzle_target_temperature"`  // Target nozzle temperature (°C)
 	NozzleTemperature       float64          `json:"nozzle_temperature"`         // Current nozzle temperature (°C)
 	Sdcard                  bool             `json:"sdcard"`                     // Whether an SD card is inserted
+	Camera                  Camera           `json:"camera"`                     // Camera settings
+	Xcam                    XcamSettings     `json:"xcam"`                       // Camera-based print monitoring settings
 	VtTray                  Tray             `json:"vt_tray"`                    // Built-in tray for use without Ams
 
 	WifiSignal string `json:"wifi_signal"` // Wi-Fi signal strength in dBm
@@ -0,0 +1,103 @@
+package bambulabs_cloud_api
+
+// remainingPercent normalizes the "remain" value reported for a tray. The printer
+// reports -1 when the amount of filament left is unknown (e.g. non-RFID spools).
+func remainingPercent(remain int) int {
+	if remain < 0 || remain > 100 {
+		return -1
+	}
+	return remain
+}
+
+// remainingGrams estimates the filament left on a spool from its remaining percentage
+// and nominal weight, returning -1 when either is unknown.
+func remainingGrams(remain, weight int) float64 {
+	percent := remainingPercent(remain)
+	if percent < 0 || weight <= 0 {
+		return -1
+	}
+	return float64(weight) * float64(percent) / 100
+}
+
+// FilamentCheck is the result of checking one tray against the filament a task needs.
+type FilamentCheck struct {
+	Location       TrayLocation `json:"location"`        // Tray the task's filament is mapped to
+	Found          bool         `json:"found"`           // Whether the tray is present on the printer
+	RequiredGrams  float64      `json:"required_grams"`  // Filament the task needs from this tray (grams)
+	RemainingGrams float64      `json:"remaining_grams"` // Estimated filament left in the tray (grams), -1 if unknown
+	Known          bool         `json:"known"`           // Whether the remaining amount could be determined
+	Sufficient     bool         `json:"sufficient"`      // Whether the tray holds enough filament to finish the task
+}
+
+// CheckFilament predicts whether each tray referenced by a task's AMS mapping has
+// enough filament to complete it. Weights mapped to the same tray are summed. Trays
+// whose remaining amount is unknown are reported with Known set to false and are
+// never considered sufficient.
+func (d Data) CheckFilament(mapping []AMSDetailMapping) []FilamentCheck {
+	checks := make([]FilamentCheck, 0, len(mapping))
+	index := make(map[TrayLocation]int)
+
+	for _, m := range mapping {
+		location, ok := mappingLocation(m)
+		if !ok {
+			continue
+		}
+
+		if i, exists := index[location]; exists {
+			checks[i].RequiredGrams += m.Weight
+			continue
+		}
+
+		check := FilamentCheck{
+			Location:       location,
+			RequiredGrams:  m.Weight,
+			RemainingGrams: -1,
+		}
+		if tray := d.tray(location); tray != nil {
+			check.Found = true
+			check.RemainingGrams = tray.RemainingGrams
+			check.Known = tray.RemainingGrams >= 0
+		}
+
+		index[location] = len(checks)
+		checks = append(checks, check)
+	}
+
+	for i := range checks {
+		checks[i].Sufficient = checks[i].Known && checks[i].RemainingGrams >= checks[i].RequiredGrams
+	}
+
+	return checks
+}
+
+// mappingLocation resolves the tray an AMS mapping entry refers to.
+func mappingLocation(m AMSDetailMapping) (TrayLocation, bool) {
+	switch {
+	case m.AMS == trayNowExternal:
+		return TrayLocation{AmsID: -1, TrayID: 0}, true
+	case m.AMS < 0 || m.AMS == trayNowNone:
+		return TrayLocation{}, false
+	default:
+		return TrayLocation{AmsID: m.AMSID, TrayID: m.SlotID}, true
+	}
+}
+
+// tray returns the tray at the given location, or nil if it is not reported.
+func (d Data) tray(location TrayLocation) *Tray {
+	if location.External() {
+		return &d.VtTray
+	}
+
+	for _, ams := range d.Ams {
+		if ams.ID != location.AmsID {
+			continue
+		}
+		for i := range ams.Trays {
+			if ams.Trays[i].ID == location.TrayID {
+				return &ams.Trays[i]
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package bambulabs_cloud_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemainingGrams(t *testing.T) {
+	assert.Equal(t, 250.0, remainingGrams(25, 1000))
+	assert.Equal(t, -1.0, remainingGrams(-1, 1000))
+	assert.Equal(t, -1.0, remainingGrams(50, 0))
+}
+
+func TestData_CheckFilament(t *testing.T) {
+	data := Data{
+		Ams: []Ams{{
+			ID: 0,
+			Trays: []Tray{
+				{ID: 0, RemainingGrams: 500},
+				{ID: 1, RemainingGrams: 20},
+				{ID: 2, RemainingGrams: -1},
+			},
+		}},
+		VtTray: Tray{ID: 254, RemainingGrams: -1},
+	}
+
+	checks := data.CheckFilament([]AMSDetailMapping{
+		{AMS: 0, AMSID: 0, SlotID: 0, Weight: 100},
+		{AMS: 1, AMSID: 0, SlotID: 1, Weight: 15},
+		{AMS: 1, AMSID: 0, SlotID: 1, Weight: 15},
+		{AMS: 2, AMSID: 0, SlotID: 2, Weight: 5},
+		{AMS: 5, AMSID: 1, SlotID: 1, Weight: 5},
+		{AMS: -1, Weight: 5},
+	})
+
+	assert.Len(t, checks, 4)
+
+	assert.True(t, checks[0].Sufficient)
+
+	assert.Equal(t, 30.0, checks[1].RequiredGrams)
+	assert.False(t, checks[1].Sufficient)
+
+	assert.True(t, checks[2].Found)
+	assert.False(t, checks[2].Known)
+	assert.False(t, checks[2].Sufficient)
+
+	assert.False(t, checks[3].Found)
+	assert.False(t, checks[3].Sufficient)
+}
@@ -0,0 +1,83 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/ftp"
+)
+
+// ErrNotLocal is returned by features only available for printers on the LAN.
+var ErrNotLocal = errors.New("only available for printers on the LAN")
+
+// Files returns a client for the files stored on the printer's SD card, through the
+// FTPS server of printers on the LAN. The client is created on first use and closed
+// by Disconnect.
+func (p *Printer) Files() (*ftp.Client, error) {
+	if !p.local {
+		return nil, ErrNotLocal
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.files == nil {
+		files, err := ftp.NewClient(&ftp.ClientConfig{
+			Host:       p.host,
+			Username:   localMqttUsername,
+			AccessCode: p.accessCode,
+			TLSConfig:  p.mqttClient.TLSConfig(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		p.files = files
+	}
+
+	return p.files, nil
+}
+
+const timelapseDir = "/timelapse"
+
+// Timelapses lists the timelapse videos recorded by a printer on the LAN, .mp4 files
+// for the X1 series and .avi files for the others.
+func (p *Printer) Timelapses() ([]ftp.File, error) {
+	files, err := p.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := files.ListFiles(timelapseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	videos := make([]ftp.File, 0, len(entries))
+	for _, entry := range entries {
+		if isTimelapseVideo(entry) {
+			videos = append(videos, entry)
+		}
+	}
+	return videos, nil
+}
+
+// DownloadTimelapse writes the timelapse video with the given name, as returned by
+// Timelapses, to w.
+func (p *Printer) DownloadTimelapse(ctx context.Context, name string, w io.Writer, progress ...ftp.ProgressFunc) error {
+	files, err := p.Files()
+	if err != nil {
+		return err
+	}
+	return files.Download(ctx, path.Join(timelapseDir, path.Base(name)), w, progress...)
+}
+
+func isTimelapseVideo(file ftp.File) bool {
+	if file.IsDir {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(file.Name))
+	return ext == ".mp4" || ext == ".avi"
+}
@@ -0,0 +1,22 @@
+package bambulabs_cloud_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/ftp"
+)
+
+func TestIsTimelapseVideo(t *testing.T) {
+	assert.True(t, isTimelapseVideo(ftp.File{Name: "video_2024-05-01_10-00-00.mp4"}))
+	assert.True(t, isTimelapseVideo(ftp.File{Name: "video_2024-05-01_10-00-00.AVI"}))
+	assert.False(t, isTimelapseVideo(ftp.File{Name: "thumbnail", IsDir: true}))
+	assert.False(t, isTimelapseVideo(ftp.File{Name: "video_2024-05-01_10-00-00.jpg"}))
+}
+
+func TestPrinter_FilesNotLocal(t *testing.T) {
+	printer := NewPrinter(&PrinterConfig{SerialNumber: "01P00A000000000"})
+
+	_, err := printer.Timelapses()
+	assert.ErrorIs(t, err, ErrNotLocal)
+}
@@ -0,0 +1,33 @@
+package bambulabs_cloud_api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/gcode"
+)
+
+// ErrModelMismatch is returned when a file was sliced for another printer model.
+var ErrModelMismatch = errors.New("file sliced for another printer model")
+
+// CheckGcode checks that an analyzed G-code file was sliced for the model of the
+// device. Files that do not name their printer model, or name one that is not
+// recognized, are accepted.
+func (d Device) CheckGcode(analysis *gcode.Analysis) error {
+	return checkGcodeModel(ModelFromName(d.DevModelName), analysis)
+}
+
+func checkGcodeModel(model Model, analysis *gcode.Analysis) error {
+	if analysis.PrinterModel == "" || model == ModelUnknown {
+		return nil
+	}
+
+	sliced := ModelFromName(analysis.PrinterModel)
+	if sliced == ModelUnknown {
+		return nil
+	}
+	if sliced != model {
+		return fmt.Errorf("%w: sliced for %q, printer is %s", ErrModelMismatch, analysis.PrinterModel, model)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package bambulabs_cloud_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/gcode"
+)
+
+func TestDevice_CheckGcode(t *testing.T) {
+	p1s := Device{DevModelName: "C12"}
+
+	assert.NoError(t, p1s.CheckGcode(&gcode.Analysis{Metadata: gcode.Metadata{PrinterModel: "Bambu Lab P1S"}}))
+	assert.NoError(t, p1s.CheckGcode(&gcode.Analysis{}))
+	assert.NoError(t, Device{}.CheckGcode(&gcode.Analysis{Metadata: gcode.Metadata{PrinterModel: "Bambu Lab P1S"}}))
+
+	err := p1s.CheckGcode(&gcode.Analysis{Metadata: gcode.Metadata{PrinterModel: "Bambu Lab X1 Carbon"}})
+	assert.ErrorIs(t, err, ErrModelMismatch)
+	assert.ErrorContains(t, err, `sliced for "Bambu Lab X1 Carbon", printer is P1S`)
+
+	// Models the library does not know cannot be compared.
+	assert.NoError(t, p1s.CheckGcode(&gcode.Analysis{Metadata: gcode.Metadata{PrinterModel: "Voron 2.4"}}))
+	assert.NoError(t, p1s.CheckGcode(&gcode.Analysis{Metadata: gcode.Metadata{PrinterModel: "Bambu Lab H9"}}))
+}
@@ -0,0 +1,327 @@
+package bambulabs_cloud_api
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/threemf"
+)
+
+const (
+	// familyPenalty is added to the colour distance of a tray holding a variant of the
+	// filament type (PLA Matte for PLA, PETG-CF for PETG...), so that trays of the
+	// exact type are preferred.
+	familyPenalty = 25
+	// sharedTrayDistance is the largest colour distance for two filaments of the same
+	// type to be fed from the same tray.
+	sharedTrayDistance = 10
+	// similarColorDistance is the largest colour distance for a tray holding another
+	// type to be suggested as a replacement.
+	similarColorDistance = 20
+)
+
+// NoTray marks, in an AMS mapping, a filament of the project that the printed plate
+// does not use.
+var NoTray = TrayLocation{AmsID: trayNowNone}
+
+// FilamentMatch is a tray proposed to feed a filament of a project.
+type FilamentMatch struct {
+	Filament      threemf.Filament `json:"filament"`       // Filament of the project
+	Location      TrayLocation     `json:"location"`       // Tray proposed to feed it
+	Tray          Tray             `json:"tray"`           // State of the tray when the mapping was made
+	ColorDistance float64          `json:"color_distance"` // CIE76 ΔE between the filament and tray colours
+	ExactType     bool             `json:"exact_type"`     // Whether the tray holds exactly the filament type
+}
+
+// FilamentMapping is the result of matching the filaments of a project to the trays
+// loaded on a printer.
+type FilamentMapping struct {
+	Matches []FilamentMatch `json:"matches"` // Matched filaments, in ID order
+}
+
+// AmsMapping returns the mapping to pass in PrintOptions: the tray feeding each
+// filament of the project, indexed by filament ID, and NoTray for the filaments
+// that were not matched.
+func (m *FilamentMapping) AmsMapping() []TrayLocation {
+	size := 0
+	for _, match := range m.Matches {
+		size = max(size, match.Filament.ID)
+	}
+
+	mapping := make([]TrayLocation, size)
+	for i := range mapping {
+		mapping[i] = NoTray
+	}
+	for _, match := range m.Matches {
+		if match.Filament.ID > 0 {
+			mapping[match.Filament.ID-1] = match.Location
+		}
+	}
+	return mapping
+}
+
+// UnmatchedFilament is a filament no loaded tray can feed.
+type UnmatchedFilament struct {
+	Filament    threemf.Filament `json:"filament"`
+	Suggestions []string         `json:"suggestions"` // Ways to make the filament available
+}
+
+// FilamentMappingError is returned when some filaments of a project cannot be fed
+// by the trays loaded on the printer.
+type FilamentMappingError struct {
+	Unmatched []UnmatchedFilament
+}
+
+func (e *FilamentMappingError) Error() string {
+	parts := make([]string, 0, len(e.Unmatched))
+	for _, unmatched := range e.Unmatched {
+		part := fmt.Sprintf("no tray for filament %d (%s)", unmatched.Filament.ID, filamentName(unmatched.Filament.Type, unmatched.Filament.Color))
+		if len(unmatched.Suggestions) > 0 {
+			part += ": " + strings.Join(unmatched.Suggestions, ", ")
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// loadedTray is a tray holding filament, with its colour converted for comparisons.
+type loadedTray struct {
+	location TrayLocation
+	tray     Tray
+	lab      lab
+}
+
+// candidate is a possible assignment of a tray to a filament.
+type candidate struct {
+	filament int // index in the filaments being mapped
+	tray     int // index in the loaded trays
+	distance float64
+	exact    bool
+}
+
+func (c candidate) cost() float64 {
+	if c.exact {
+		return c.distance
+	}
+	return c.distance + familyPenalty
+}
+
+// MapFilaments proposes the tray feeding each of the given filaments, such as the
+// filaments of a plate returned by threemf.Project.PlateFilaments. A tray is
+// compatible with a filament if it holds the same type, or a variant of it, and
+// their nozzle temperature ranges overlap; among compatible trays, the closest in
+// colour is chosen. Each tray feeds a single filament, unless two filaments of the
+// same type have nearly the same colour.
+//
+// Filaments that cannot be matched are reported in a *FilamentMappingError, along
+// with the mapping of the other filaments.
+func (d Data) MapFilaments(filaments []threemf.Filament) (*FilamentMapping, error) {
+	trays := d.loadedTrays()
+
+	var candidates []candidate
+	for i, filament := range filaments {
+		want := toLab(filament.Color)
+		for j, tray := range trays {
+			exact, ok := compatibleFilament(filament, tray.tray)
+			if !ok {
+				continue
+			}
+			candidates = append(candidates, candidate{filament: i, tray: j, distance: want.distance(tray.lab), exact: exact})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].cost() < candidates[j].cost() })
+
+	assigned := make(map[int]candidate, len(filaments))
+	used := make(map[int]int, len(trays)) // tray index to filament index
+	for _, c := range candidates {
+		if _, ok := assigned[c.filament]; ok {
+			continue
+		}
+		if _, ok := used[c.tray]; ok {
+			continue
+		}
+		assigned[c.filament] = c
+		used[c.tray] = c.filament
+	}
+
+	// Filaments left over may share a tray with a filament of the same type and colour.
+	for _, c := range candidates {
+		if _, ok := assigned[c.filament]; ok {
+			continue
+		}
+		if c.exact && c.distance <= sharedTrayDistance {
+			assigned[c.filament] = c
+		}
+	}
+
+	mapping := &FilamentMapping{}
+	var unmatched []UnmatchedFilament
+	for i, filament := range filaments {
+		c, ok := assigned[i]
+		if !ok {
+			unmatched = append(unmatched, UnmatchedFilament{
+				Filament:    filament,
+				Suggestions: suggestTrays(filament, filaments, trays, used),
+			})
+			continue
+		}
+		mapping.Matches = append(mapping.Matches, FilamentMatch{
+			Filament:      filament,
+			Location:      trays[c.tray].location,
+			Tray:          trays[c.tray].tray,
+			ColorDistance: c.distance,
+			ExactType:     c.exact,
+		})
+	}
+	sort.Slice(mapping.Matches, func(i, j int) bool { return mapping.Matches[i].Filament.ID < mapping.Matches[j].Filament.ID })
+
+	if len(unmatched) > 0 {
+		return mapping, &FilamentMappingError{Unmatched: unmatched}
+	}
+	return mapping, nil
+}
+
+// loadedTrays returns the trays holding filament, AMS trays first.
+func (d Data) loadedTrays() []loadedTray {
+	var trays []loadedTray
+	for _, ams := range d.Ams {
+		for _, tray := range ams.Trays {
+			if !tray.Present || tray.TrayType == "" {
+				continue
+			}
+			trays = append(trays, loadedTray{
+				location: TrayLocation{AmsID: ams.ID, TrayID: tray.ID},
+				tray:     tray,
+				lab:      toLab(tray.TrayColor),
+			})
+		}
+	}
+	if d.VtTray.TrayType != "" {
+		trays = append(trays, loadedTray{
+			location: TrayLocation{AmsID: -1, TrayID: 0},
+			tray:     d.VtTray,
+			lab:      toLab(d.VtTray.TrayColor),
+		})
+	}
+	return trays
+}
+
+// suggestTrays lists ways to feed a filament that could not be matched.
+func suggestTrays(filament threemf.Filament, filaments []threemf.Filament, trays []loadedTray, used map[int]int) []string {
+	var suggestions []string
+	want := toLab(filament.Color)
+
+	for i, tray := range trays {
+		if _, ok := compatibleFilament(filament, tray.tray); ok {
+			if other, ok := used[i]; ok {
+				suggestions = append(suggestions, fmt.Sprintf("%s holds %s but feeds filament %d",
+					trayName(tray.location), filamentName(tray.tray.TrayType, tray.tray.TrayColor), filaments[other].ID))
+			}
+			continue
+		}
+		if want.distance(tray.lab) <= similarColorDistance {
+			suggestions = append(suggestions, fmt.Sprintf("%s holds %s in a similar colour",
+				trayName(tray.location), filamentName(tray.tray.TrayType, tray.tray.TrayColor)))
+		}
+	}
+
+	return append(suggestions, "load "+filamentName(filament.Type, filament.Color))
+}
+
+// compatibleFilament reports whether a tray can feed a filament, and whether it
+// holds exactly the filament type rather than a variant of it.
+func compatibleFilament(filament threemf.Filament, tray Tray) (exact, ok bool) {
+	want := normalizeFilamentType(filament.Type)
+	have := normalizeFilamentType(tray.TrayType)
+	if want == "" || have == "" {
+		return false, false
+	}
+
+	if filament.NozzleTempMax > 0 && tray.NozzleTempMax > 0 &&
+		(filament.NozzleTempMin > tray.NozzleTempMax || tray.NozzleTempMin > filament.NozzleTempMax) {
+		return false, false
+	}
+
+	if want == have {
+		return true, true
+	}
+	// Support materials (PLA-S, PA-S) are not interchangeable with the base material.
+	if isSupportFilament(want) || isSupportFilament(have) {
+		return false, false
+	}
+	return false, filamentFamily(want) == filamentFamily(have)
+}
+
+func normalizeFilamentType(t string) string {
+	return strings.ToUpper(strings.TrimSpace(t))
+}
+
+// filamentFamily returns the base material of a filament type: PLA for "PLA MATTE"
+// and "PLA-CF", PETG for "PETG-CF".
+func filamentFamily(t string) string {
+	family, _, _ := strings.Cut(t, "-")
+	family, _, _ = strings.Cut(family, " ")
+	return family
+}
+
+func isSupportFilament(t string) bool {
+	return strings.HasSuffix(t, "-S") || t == "SUPPORT" || t == "PVA" || t == "BVOH"
+}
+
+// trayName returns the name of a tray as shown on the printer: A1 to D4 for AMS
+// trays, HT-A for AMS HT units and Ext for the external spool.
+func trayName(location TrayLocation) string {
+	switch {
+	case location.External():
+		return "Ext"
+	case location.AmsID >= amsHTFirstID:
+		return fmt.Sprintf("HT-%c", 'A'+rune(location.AmsID-amsHTFirstID))
+	default:
+		return fmt.Sprintf("%c%d", 'A'+rune(location.AmsID), location.TrayID+1)
+	}
+}
+
+func filamentName(filamentType string, c color.RGBA) string {
+	return fmt.Sprintf("%s #%02X%02X%02X", filamentType, c.R, c.G, c.B)
+}
+
+// lab is a colour in the CIE L*a*b* space, where the euclidean distance between two
+// colours approximates how different they look.
+type lab struct {
+	l, a, b float64
+}
+
+// toLab converts an sRGB colour to CIE L*a*b* under the D65 illuminant.
+func toLab(c color.RGBA) lab {
+	linear := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.04045 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	r, g, b := linear(c.R), linear(c.G), linear(c.B)
+
+	// XYZ normalised by the D65 white point.
+	x := (0.4124*r + 0.3576*g + 0.1805*b) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := (0.0193*r + 0.1192*g + 0.9505*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+
+	return lab{l: 116*fy - 16, a: 500 * (fx - fy), b: 200 * (fy - fz)}
+}
+
+// distance returns the CIE76 colour difference ΔE*ab.
+func (c lab) distance(other lab) float64 {
+	return math.Sqrt((c.l-other.l)*(c.l-other.l) + (c.a-other.a)*(c.a-other.a) + (c.b-other.b)*(c.b-other.b))
+}
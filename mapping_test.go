@@ -0,0 +1,173 @@
+package bambulabs_cloud_api
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/threemf"
+)
+
+var (
+	white  = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	black  = color.RGBA{A: 0xff}
+	red    = color.RGBA{R: 0xc1, G: 0x2e, B: 0x1f, A: 0xff}
+	orange = color.RGBA{R: 0xff, G: 0x6a, B: 0x13, A: 0xff}
+	blue   = color.RGBA{R: 0x0a, G: 0x29, B: 0x89, A: 0xff}
+)
+
+func loadedData() Data {
+	return Data{
+		Ams: []Ams{{
+			ID: 0,
+			Trays: []Tray{
+				{ID: 0, Present: true, TrayType: "PLA", TrayColor: black, NozzleTempMin: 190, NozzleTempMax: 230},
+				{ID: 1, Present: true, TrayType: "PLA", TrayColor: red, NozzleTempMin: 190, NozzleTempMax: 230},
+				{ID: 2, Present: true, TrayType: "PETG", TrayColor: blue, NozzleTempMin: 220, NozzleTempMax: 260},
+				{ID: 3},
+			},
+		}, {
+			ID: 1,
+			Trays: []Tray{
+				{ID: 0, Present: true, TrayType: "PLA", TrayColor: white, NozzleTempMin: 190, NozzleTempMax: 230},
+				{ID: 1, Present: true, TrayType: "PLA-S", TrayColor: white, NozzleTempMin: 190, NozzleTempMax: 230},
+			},
+		}},
+		VtTray: Tray{TrayType: "TPU", TrayColor: orange},
+	}
+}
+
+func TestData_MapFilaments(t *testing.T) {
+	mapping, err := loadedData().MapFilaments([]threemf.Filament{
+		{ID: 1, Type: "PLA", Color: white},
+		{ID: 3, Type: "PLA", Color: color.RGBA{R: 0xd0, G: 0x30, B: 0x20, A: 0xff}},
+		{ID: 4, Type: "PETG", Color: color.RGBA{R: 0x10, G: 0x30, B: 0x90, A: 0xff}, NozzleTempMin: 230, NozzleTempMax: 250},
+		{ID: 5, Type: "tpu", Color: orange},
+	})
+	require.NoError(t, err)
+	require.Len(t, mapping.Matches, 4)
+
+	assert.Equal(t, TrayLocation{AmsID: 1, TrayID: 0}, mapping.Matches[0].Location)
+	assert.Equal(t, TrayLocation{AmsID: 0, TrayID: 1}, mapping.Matches[1].Location)
+	assert.Equal(t, TrayLocation{AmsID: 0, TrayID: 2}, mapping.Matches[2].Location)
+	assert.Equal(t, TrayLocation{AmsID: -1}, mapping.Matches[3].Location)
+	assert.True(t, mapping.Matches[3].ExactType)
+	assert.Zero(t, mapping.Matches[3].ColorDistance)
+
+	assert.Equal(t, []TrayLocation{
+		{AmsID: 1, TrayID: 0}, NoTray, {AmsID: 0, TrayID: 1}, {AmsID: 0, TrayID: 2}, {AmsID: -1},
+	}, mapping.AmsMapping())
+}
+
+func TestData_MapFilamentsClosestColorWins(t *testing.T) {
+	// The red filament is closer to the red tray than the black one is, so the black
+	// filament must not take it even though it comes first.
+	mapping, err := loadedData().MapFilaments([]threemf.Filament{
+		{ID: 1, Type: "PLA", Color: color.RGBA{R: 0x30, A: 0xff}},
+		{ID: 2, Type: "PLA", Color: red},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []TrayLocation{{AmsID: 0, TrayID: 0}, {AmsID: 0, TrayID: 1}}, mapping.AmsMapping())
+}
+
+func TestData_MapFilamentsVariants(t *testing.T) {
+	data := Data{Ams: []Ams{{ID: 0, Trays: []Tray{
+		{ID: 0, Present: true, TrayType: "PLA-CF", TrayColor: black},
+		{ID: 1, Present: true, TrayType: "PLA", TrayColor: color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}},
+	}}}}
+
+	mapping, err := data.MapFilaments([]threemf.Filament{{ID: 1, Type: "PLA", Color: black}})
+	require.NoError(t, err)
+	assert.Equal(t, TrayLocation{AmsID: 0, TrayID: 1}, mapping.Matches[0].Location, "exact type preferred over a closer variant")
+
+	mapping, err = data.MapFilaments([]threemf.Filament{{ID: 1, Type: "PLA", Color: black}, {ID: 2, Type: "PLA", Color: black}})
+	require.NoError(t, err)
+	assert.False(t, mapping.Matches[1].ExactType)
+	assert.Equal(t, TrayLocation{AmsID: 0, TrayID: 0}, mapping.Matches[1].Location)
+}
+
+func TestData_MapFilamentsSharedTray(t *testing.T) {
+	mapping, err := loadedData().MapFilaments([]threemf.Filament{
+		{ID: 1, Type: "PETG", Color: blue},
+		{ID: 2, Type: "PETG", Color: color.RGBA{R: 0x0c, G: 0x2a, B: 0x8a, A: 0xff}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []TrayLocation{{AmsID: 0, TrayID: 2}, {AmsID: 0, TrayID: 2}}, mapping.AmsMapping())
+}
+
+func TestData_MapFilamentsUnmatched(t *testing.T) {
+	mapping, err := loadedData().MapFilaments([]threemf.Filament{
+		{ID: 1, Type: "PETG", Color: blue},
+		{ID: 2, Type: "PETG", Color: white},
+		{ID: 3, Type: "ABS", Color: color.RGBA{R: 0xc4, G: 0x30, B: 0x22, A: 0xff}},
+		{ID: 4, Type: "PLA", Color: white, NozzleTempMin: 300, NozzleTempMax: 320},
+	})
+
+	var mappingErr *FilamentMappingError
+	require.ErrorAs(t, err, &mappingErr)
+	require.Len(t, mappingErr.Unmatched, 3)
+
+	assert.Equal(t, 2, mappingErr.Unmatched[0].Filament.ID)
+	assert.Equal(t, []string{
+		"A3 holds PETG #0A2989 but feeds filament 1",
+		"B1 holds PLA #FFFFFF in a similar colour",
+		"B2 holds PLA-S #FFFFFF in a similar colour",
+		"load PETG #FFFFFF",
+	}, mappingErr.Unmatched[0].Suggestions)
+
+	assert.Equal(t, 3, mappingErr.Unmatched[1].Filament.ID)
+	assert.Equal(t, []string{"A2 holds PLA #C12E1F in a similar colour", "load ABS #C43022"}, mappingErr.Unmatched[1].Suggestions)
+
+	assert.Equal(t, 4, mappingErr.Unmatched[2].Filament.ID)
+	assert.Contains(t, err.Error(), "no tray for filament 4 (PLA #FFFFFF)")
+
+	// The filaments that could be matched are still mapped.
+	require.Len(t, mapping.Matches, 1)
+	assert.Equal(t, []TrayLocation{{AmsID: 0, TrayID: 2}}, mapping.AmsMapping())
+}
+
+func TestCompatibleFilament(t *testing.T) {
+	tests := []struct {
+		want, have string
+		exact, ok  bool
+	}{
+		{want: "PLA", have: "PLA", exact: true, ok: true},
+		{want: "pla", have: "PLA ", exact: true, ok: true},
+		{want: "PLA", have: "PLA Matte", ok: true},
+		{want: "PETG", have: "PETG-CF", ok: true},
+		{want: "PLA", have: "PLA-S"},
+		{want: "PLA-S", have: "PLA"},
+		{want: "PLA", have: "PETG"},
+		{want: "PET", have: "PETG"},
+		{want: "PLA", have: ""},
+	}
+
+	for _, tt := range tests {
+		exact, ok := compatibleFilament(threemf.Filament{Type: tt.want}, Tray{TrayType: tt.have})
+		assert.Equal(t, tt.ok, ok, "%s in %s", tt.want, tt.have)
+		assert.Equal(t, tt.exact, exact, "%s in %s", tt.want, tt.have)
+	}
+}
+
+func TestToLab(t *testing.T) {
+	white := toLab(white)
+	assert.InDelta(t, 100, white.l, 0.01)
+	assert.InDelta(t, 0, white.a, 0.05)
+	assert.InDelta(t, 0, white.b, 0.05)
+
+	assert.InDelta(t, 0, toLab(black).l, 0.01)
+	assert.InDelta(t, 100, white.distance(toLab(black)), 0.01)
+
+	red := toLab(color.RGBA{R: 0xff, A: 0xff})
+	assert.InDelta(t, 53.24, red.l, 0.05)
+	assert.InDelta(t, 80.09, red.a, 0.05)
+	assert.InDelta(t, 67.20, red.b, 0.05)
+}
+
+func TestTrayName(t *testing.T) {
+	assert.Equal(t, "A1", trayName(TrayLocation{AmsID: 0, TrayID: 0}))
+	assert.Equal(t, "C4", trayName(TrayLocation{AmsID: 2, TrayID: 3}))
+	assert.Equal(t, "HT-B", trayName(TrayLocation{AmsID: 129}))
+	assert.Equal(t, "Ext", trayName(TrayLocation{AmsID: -1}))
+}
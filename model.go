@@ -0,0 +1,81 @@
+package bambulabs_cloud_api
+
+import "strings"
+
+type Model string
+
+const (
+	ModelUnknown Model = ""
+	ModelX1C     Model = "X1C"
+	ModelX1      Model = "X1"
+	ModelX1E     Model = "X1E"
+	ModelP1P     Model = "P1P"
+	ModelP1S     Model = "P1S"
+	ModelA1      Model = "A1"
+	ModelA1Mini  Model = "A1 mini"
+	ModelH2D     Model = "H2D"
+)
+
+// serialPrefixes maps the first characters of a serial number to the printer model.
+var serialPrefixes = map[string]Model{
+	"00M": ModelX1C,
+	"00W": ModelX1,
+	"03W": ModelX1E,
+	"01S": ModelP1P,
+	"01P": ModelP1S,
+	"039": ModelA1,
+	"030": ModelA1Mini,
+	"094": ModelH2D,
+}
+
+// modelNames maps the model names used by the cloud API (Device.DevModelName) and
+// in sliced files to the printer model. Keys are upper case.
+var modelNames = map[string]Model{
+	"BL-P001": ModelX1C,
+	"BL-P002": ModelX1,
+	"C13":     ModelX1E,
+	"C11":     ModelP1P,
+	"C12":     ModelP1S,
+	"N2S":     ModelA1,
+	"N1":      ModelA1Mini,
+	"O1D":     ModelH2D,
+
+	// printer_model setting written by the slicers in G-code files.
+	"BAMBU LAB X1 CARBON": ModelX1C,
+	"BAMBU LAB X1":        ModelX1,
+	"BAMBU LAB X1E":       ModelX1E,
+	"BAMBU LAB P1P":       ModelP1P,
+	"BAMBU LAB P1S":       ModelP1S,
+	"BAMBU LAB A1":        ModelA1,
+	"BAMBU LAB A1 MINI":   ModelA1Mini,
+	"BAMBU LAB H2D":       ModelH2D,
+}
+
+// ModelFromSerial returns the model of a printer from its serial number.
+func ModelFromSerial(serial string) Model {
+	if len(serial) < 3 {
+		return ModelUnknown
+	}
+	return serialPrefixes[strings.ToUpper(serial[:3])]
+}
+
+// ModelFromName returns the model matching a model name such as Device.DevModelName
+// ("BL-P001", "C12", "N2S"...) or the printer_model of a G-code file ("Bambu Lab P1S").
+func ModelFromName(name string) Model {
+	return modelNames[strings.ToUpper(strings.TrimSpace(name))]
+}
+
+// IsX1 reports whether the model belongs to the X1 series, which differs from the
+// other printers for its camera and storage.
+func (m Model) IsX1() bool {
+	return m == ModelX1C || m == ModelX1 || m == ModelX1E
+}
+
+// Model returns the model of the printer, derived from its serial number or, for
+// serials not known to the library, from the cloud metadata.
+func (p *Printer) Model() Model {
+	if model := ModelFromSerial(p.serial); model != ModelUnknown {
+		return model
+	}
+	return ModelFromName(p.Device().DevModelName)
+}
@@ -0,0 +1,20 @@
+package bambulabs_cloud_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelFromSerial(t *testing.T) {
+	assert.Equal(t, ModelX1C, ModelFromSerial("00M00A000000000"))
+	assert.Equal(t, ModelP1S, ModelFromSerial("01P00A000000000"))
+	assert.Equal(t, ModelA1Mini, ModelFromSerial("0300AA000000000"))
+	assert.Equal(t, ModelUnknown, ModelFromSerial("XY"))
+	assert.Equal(t, ModelX1C, ModelFromName("BL-P001"))
+	assert.Equal(t, ModelA1, ModelFromName("N2S"))
+	assert.Equal(t, ModelX1C, ModelFromName("Bambu Lab X1 Carbon"))
+	assert.Equal(t, ModelA1Mini, ModelFromName("Bambu Lab A1 mini"))
+	assert.True(t, ModelX1E.IsX1())
+	assert.False(t, ModelP1P.IsX1())
+}
@@ -0,0 +1,28 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+// SkipObjects stops printing the objects with the given IDs for the rest of the
+// current print. The IDs are those of threemf.Object, read from the sliced project;
+// objects already skipped are reported in Data.SkippedObjects.
+func (p *Printer) SkipObjects(ctx context.Context, ids ...int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := p.mqttClient.Request(ctx, p.serial, skipObjectsCommand(ids)); err != nil {
+		return fmt.Errorf("skip_objects failed: %w", err)
+	}
+	return nil
+}
+
+func skipObjectsCommand(ids []int) *mqtt.Command {
+	return mqtt.NewCommand(mqtt.Print).
+		AddCommandField("skip_objects").
+		AddField("obj_list", ids)
+}
@@ -0,0 +1,20 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipObjectsCommand(t *testing.T) {
+	fields := commandFields(t, skipObjectsCommand([]int{143, 211}))
+
+	assert.Equal(t, "skip_objects", fields["command"])
+	assert.Equal(t, []any{143.0, 211.0}, fields["obj_list"])
+}
+
+func TestPrinter_SkipNoObjects(t *testing.T) {
+	printer := NewPrinter(&PrinterConfig{SerialNumber: "01P00A000000000"})
+	assert.NoError(t, printer.SkipObjects(context.Background()))
+}
@@ -0,0 +1,218 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+// The P1 and A1 series serve their camera over TLS on port 6000. After an
+// authentication packet, the printer sends one JPEG image per frame, each preceded
+// by a 16 byte header holding the size of the image.
+
+const (
+	DefaultPort     = 6000
+	DefaultUsername = "bblp"
+	defaultTimeout  = 10 * time.Second
+
+	authPacketType = 0x3000
+	authFieldSize  = 32
+	headerSize     = 16
+	maxFrameSize   = 16 << 20
+
+	reconnectDelay = time.Second
+)
+
+var (
+	jpegStart = []byte{0xff, 0xd8}
+	jpegEnd   = []byte{0xff, 0xd9}
+)
+
+// ErrInvalidFrame is returned when the printer sends data that is not a JPEG frame.
+var ErrInvalidFrame = errors.New("invalid camera frame")
+
+type Frame struct {
+	Image []byte    // JPEG encoded image
+	Time  time.Time // Time the frame was received
+}
+
+type ClientConfig struct {
+	Host       string
+	Port       int // Defaults to DefaultPort
+	Username   string
+	AccessCode string
+	Timeout    time.Duration
+	TLSConfig  *tls.Config
+}
+
+// Client reads the camera of a P1 or A1 series printer on the LAN.
+type Client struct {
+	config *ClientConfig
+}
+
+func NewClient(config *ClientConfig) *Client {
+	return &Client{config: config}
+}
+
+// Snapshot connects to the camera and returns the first frame it sends.
+func (c *Client) Snapshot(ctx context.Context) (Frame, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return Frame{}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	frame, err := NewReader(conn).ReadFrame()
+	if err != nil {
+		if ctx.Err() != nil {
+			return Frame{}, ctx.Err()
+		}
+		return Frame{}, err
+	}
+	return frame, nil
+}
+
+// Stream sends the frames of the camera on the returned channel until ctx is done,
+// reconnecting when the connection drops. Frames are dropped while the receiver is
+// not ready, so that it always gets the most recent one.
+func (c *Client) Stream(ctx context.Context) <-chan Frame {
+	frames := make(chan Frame, 1)
+
+	go func() {
+		defer close(frames)
+		for {
+			if err := c.stream(ctx, frames); err != nil && ctx.Err() == nil {
+				log.Printf("Camera stream of %s interrupted: %v", c.config.Host, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reconnectDelay):
+			}
+		}
+	}()
+
+	return frames
+}
+
+func (c *Client) stream(ctx context.Context, frames chan Frame) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	reader := NewReader(conn)
+	for {
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case frames <- frame:
+		default:
+			select {
+			case <-frames:
+			default:
+			}
+			frames <- frame
+		}
+	}
+}
+
+// dial connects to the camera and authenticates.
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	port := c.config.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	timeout := c.config.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    c.config.TLSConfig,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.config.Host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to camera: %w", err)
+	}
+
+	username := c.config.Username
+	if username == "" {
+		username = DefaultUsername
+	}
+	if _, err := conn.Write(authPacket(username, c.config.AccessCode)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to authenticate to camera: %w", err)
+	}
+
+	return conn, nil
+}
+
+// authPacket builds the packet sent to the camera after connecting: a 16 byte header
+// followed by the username and access code, each padded to 32 bytes.
+func authPacket(username, accessCode string) []byte {
+	packet := make([]byte, headerSize+2*authFieldSize)
+	binary.LittleEndian.PutUint32(packet[0:], 2*authFieldSize)
+	binary.LittleEndian.PutUint32(packet[4:], authPacketType)
+	copy(packet[headerSize:headerSize+authFieldSize], username)
+	copy(packet[headerSize+authFieldSize:], accessCode)
+	return packet
+}
+
+// Reader reads camera frames from a stream.
+type Reader struct {
+	r      io.Reader
+	header [headerSize]byte
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadFrame reads the next frame. It returns io.EOF if the stream ended between two
+// frames and ErrInvalidFrame if the data is not a JPEG frame.
+func (r *Reader) ReadFrame() (Frame, error) {
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Frame{}, fmt.Errorf("%w: truncated header", ErrInvalidFrame)
+		}
+		return Frame{}, err
+	}
+
+	size := binary.LittleEndian.Uint32(r.header[0:])
+	if size < uint32(len(jpegStart)+len(jpegEnd)) || size > maxFrameSize {
+		return Frame{}, fmt.Errorf("%w: size %d", ErrInvalidFrame, size)
+	}
+
+	image := make([]byte, size)
+	if _, err := io.ReadFull(r.r, image); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return Frame{}, fmt.Errorf("%w: truncated image", ErrInvalidFrame)
+		}
+		return Frame{}, err
+	}
+
+	if !bytes.HasPrefix(image, jpegStart) || !bytes.HasSuffix(image, jpegEnd) {
+		return Frame{}, fmt.Errorf("%w: not a JPEG image", ErrInvalidFrame)
+	}
+
+	return Frame{Image: image, Time: time.Now()}, nil
+}
@@ -0,0 +1,185 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func jpegFrame(content string) []byte {
+	return append(append([]byte{0xff, 0xd8, 0xff, 0xe0}, content...), 0xff, 0xd9)
+}
+
+func framePacket(image []byte) []byte {
+	header := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(header, uint32(len(image)))
+	return append(header, image...)
+}
+
+func TestReader_ReadFrame(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(framePacket(jpegFrame("first")))
+	stream.Write(framePacket(jpegFrame("second")))
+
+	reader := NewReader(&stream)
+
+	frame, err := reader.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, jpegFrame("first"), frame.Image)
+
+	frame, err = reader.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, jpegFrame("second"), frame.Image)
+
+	_, err = reader.ReadFrame()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReader_InvalidFrames(t *testing.T) {
+	oversized := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(oversized, maxFrameSize+1)
+
+	tests := []struct {
+		name   string
+		stream []byte
+	}{
+		{name: "truncated header", stream: []byte{1, 2, 3}},
+		{name: "truncated image", stream: framePacket(jpegFrame("frame"))[:headerSize+4]},
+		{name: "oversized", stream: oversized},
+		{name: "empty", stream: framePacket(nil)},
+		{name: "not a jpeg", stream: framePacket([]byte("not an image at all"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(bytes.NewReader(tt.stream)).ReadFrame()
+			assert.ErrorIs(t, err, ErrInvalidFrame)
+		})
+	}
+}
+
+func TestAuthPacket(t *testing.T) {
+	packet := authPacket("bblp", "12345678")
+
+	require.Len(t, packet, 80)
+	assert.Equal(t, uint32(64), binary.LittleEndian.Uint32(packet[0:]))
+	assert.Equal(t, uint32(0x3000), binary.LittleEndian.Uint32(packet[4:]))
+	assert.Equal(t, "bblp", string(bytes.TrimRight(packet[16:48], "\x00")))
+	assert.Equal(t, "12345678", string(bytes.TrimRight(packet[48:80], "\x00")))
+}
+
+// newTestCamera starts a TLS server behaving like the camera of a printer: it checks
+// the authentication packet, then sends frames until the connection is closed. Each
+// connection gets the frames of one batch, then the server hangs up.
+func newTestCamera(t *testing.T, accessCode string, frames ...[]byte) *ClientConfig {
+	t.Helper()
+	listener := newTestListener(t)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				auth := make([]byte, 80)
+				if _, err := io.ReadFull(conn, auth); err != nil {
+					return
+				}
+				if string(bytes.TrimRight(auth[48:], "\x00")) != accessCode {
+					return
+				}
+				for _, frame := range frames {
+					if _, err := conn.Write(framePacket(frame)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return &ClientConfig{
+		Host:       addr.IP.String(),
+		Port:       addr.Port,
+		AccessCode: accessCode,
+		TLSConfig:  &tls.Config{InsecureSkipVerify: true},
+	}
+}
+
+// newTestListener returns a TLS listener with a self-signed certificate, closed
+// when the test ends.
+func newTestListener(t *testing.T) net.Listener {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "01S00A000000000"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	return listener
+}
+
+func TestClient_Snapshot(t *testing.T) {
+	client := NewClient(newTestCamera(t, "12345678", jpegFrame("snapshot"), jpegFrame("next")))
+
+	frame, err := client.Snapshot(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, jpegFrame("snapshot"), frame.Image)
+	assert.False(t, frame.Time.IsZero())
+}
+
+func TestClient_SnapshotWrongAccessCode(t *testing.T) {
+	config := newTestCamera(t, "12345678", jpegFrame("snapshot"))
+	config.AccessCode = "wrong"
+
+	_, err := NewClient(config).Snapshot(context.Background())
+	assert.Error(t, err)
+}
+
+func TestClient_Stream(t *testing.T) {
+	client := NewClient(newTestCamera(t, "12345678", jpegFrame("a")))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	frames := client.Stream(ctx)
+
+	// The server hangs up after each frame, so receiving two frames means the
+	// client reconnected.
+	for range 2 {
+		select {
+		case frame := <-frames:
+			assert.Equal(t, jpegFrame("a"), frame.Image)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no frame received")
+		}
+	}
+
+	cancel()
+	for range frames {
+	}
+}
@@ -0,0 +1,206 @@
+package camera
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"sync"
+)
+
+// The H.264 stream of X1 series cameras arrives as RTP packets (RFC 6184), which
+// are reassembled into access units, the NAL units of one picture, and handed to
+// a decoder. The standard library has no H.264 decoder, so one must be registered
+// with RegisterH264Decoder, the way image formats are registered with the image
+// package.
+
+// NAL unit types, see ITU-T H.264 table 7-1 and RFC 6184 section 5.2.
+const (
+	nalIDR   = 5
+	nalSPS   = 7
+	nalPPS   = 8
+	nalSTAPA = 24
+	nalFUA   = 28
+)
+
+// ErrNoDecoder is returned for X1 series cameras when no H.264 decoder is
+// registered, see RegisterH264Decoder.
+var ErrNoDecoder = errors.New("no H.264 decoder registered")
+
+// H264Decoder decodes an H.264 stream. Decode is called with the NAL units of each
+// access unit in stream order, without start codes, starting with a keyframe
+// preceded by its parameter sets. It returns the decoded picture, or nil if the
+// access unit does not complete one. Decoders implementing io.Closer are closed
+// when the stream ends.
+type H264Decoder interface {
+	Decode(nalus [][]byte) (image.Image, error)
+}
+
+var (
+	decoderMu  sync.RWMutex
+	newDecoder func() (H264Decoder, error)
+)
+
+// RegisterH264Decoder registers the function creating the decoder of each H.264
+// stream, replacing any previous one; nil unregisters it. Packages wrapping a
+// decoder, such as FFmpeg's libavcodec through cgo, typically call it from their
+// init function.
+func RegisterH264Decoder(decoder func() (H264Decoder, error)) {
+	decoderMu.Lock()
+	defer decoderMu.Unlock()
+	newDecoder = decoder
+}
+
+// HasH264Decoder reports whether an H.264 decoder is registered.
+func HasH264Decoder() bool {
+	decoderMu.RLock()
+	defer decoderMu.RUnlock()
+	return newDecoder != nil
+}
+
+func h264Decoder() (H264Decoder, error) {
+	decoderMu.RLock()
+	decoder := newDecoder
+	decoderMu.RUnlock()
+	if decoder == nil {
+		return nil, ErrNoDecoder
+	}
+	return decoder()
+}
+
+// accessUnit holds the NAL units of one picture.
+type accessUnit [][]byte
+
+// h264Depacketizer reassembles the access units of an H.264 RTP stream sent in
+// non-interleaved mode: single NAL unit, STAP-A and FU-A packets.
+type h264Depacketizer struct {
+	nalus     accessUnit
+	fragment  []byte // FU-A being reassembled
+	timestamp uint32
+	sequence  uint16
+	started   bool
+	broken    bool // Part of the current access unit is missing
+}
+
+// push adds a packet and returns the access units it completes. An access unit
+// missing packets is returned as nil, as the pictures following it cannot be
+// decoded before the next keyframe.
+func (d *h264Depacketizer) push(packet rtpPacket) []accessUnit {
+	var units []accessUnit
+	if d.started && packet.sequence != d.sequence+1 {
+		d.broken = true
+	}
+	// The marker ending the previous access unit was lost.
+	if d.started && packet.timestamp != d.timestamp && (len(d.nalus) > 0 || d.fragment != nil || d.broken) {
+		units = append(units, d.flush())
+	}
+	d.started, d.sequence, d.timestamp = true, packet.sequence, packet.timestamp
+
+	d.add(packet.payload)
+	if packet.marker {
+		units = append(units, d.flush())
+	}
+	return units
+}
+
+func (d *h264Depacketizer) add(payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+
+	switch nalType := payload[0] & 0x1f; {
+	case nalType >= 1 && nalType <= 23:
+		d.nalus = append(d.nalus, payload)
+	case nalType == nalSTAPA:
+		for rest := payload[1:]; len(rest) > 0; {
+			if len(rest) < 2 {
+				d.broken = true
+				return
+			}
+			size := int(binary.BigEndian.Uint16(rest))
+			if size == 0 || size > len(rest)-2 {
+				d.broken = true
+				return
+			}
+			d.nalus = append(d.nalus, rest[2:2+size])
+			rest = rest[2+size:]
+		}
+	case nalType == nalFUA:
+		if len(payload) < 2 {
+			d.broken = true
+			return
+		}
+		header := payload[1]
+		switch {
+		case header&0x80 != 0:
+			d.fragment = append([]byte{payload[0]&0xe0 | header&0x1f}, payload[2:]...)
+		case d.fragment != nil:
+			d.fragment = append(d.fragment, payload[2:]...)
+		default:
+			// The start of the fragmented NAL unit was missed.
+			d.broken = true
+			return
+		}
+		if header&0x40 != 0 {
+			d.nalus = append(d.nalus, d.fragment)
+			d.fragment = nil
+		}
+	default:
+		// STAP-B, MTAP and FU-B only exist in interleaved mode.
+		d.broken = true
+	}
+}
+
+func (d *h264Depacketizer) flush() accessUnit {
+	unit := d.nalus
+	if d.broken || d.fragment != nil {
+		unit = nil
+	}
+	d.nalus, d.fragment, d.broken = nil, nil, false
+	return unit
+}
+
+// h264Pictures feeds access units to a decoder. Decoding starts at a keyframe,
+// preceded by the parameter sets of the session description when the stream does
+// not repeat them, and starts over at the next one after a lost access unit or a
+// decoding error.
+type h264Pictures struct {
+	decoder  H264Decoder
+	sps, pps []byte
+	synced   bool
+}
+
+func (h *h264Pictures) decode(unit accessUnit) (image.Image, error) {
+	if unit == nil {
+		h.synced = false
+		return nil, nil
+	}
+
+	idr, sps := false, false
+	for _, nalu := range unit {
+		switch nalu[0] & 0x1f {
+		case nalIDR:
+			idr = true
+		case nalSPS:
+			h.sps, sps = nalu, true
+		case nalPPS:
+			h.pps = nalu
+		}
+	}
+
+	if !h.synced {
+		if !idr {
+			return nil, nil
+		}
+		if !sps && h.sps != nil && h.pps != nil {
+			unit = append(accessUnit{h.sps, h.pps}, unit...)
+		}
+		h.synced = true
+	}
+
+	img, err := h.decoder.Decode(unit)
+	if err != nil {
+		h.synced = false
+		return nil, err
+	}
+	return img, nil
+}
@@ -0,0 +1,186 @@
+package camera
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testSPS = []byte{0x67, 0x64, 0x00, 0x28}
+	testPPS = []byte{0x68, 0xee, 0x3c, 0x80}
+	testIDR = []byte{0x65, 0x88, 0x84, 0x00, 0x33, 0xff, 0x01, 0x02, 0x03, 0x04}
+	testP   = []byte{0x41, 0x9a, 0x21, 0x6c}
+)
+
+// testDecoder records the access units it decodes and returns a picture for each.
+type testDecoder struct {
+	mu     sync.Mutex
+	units  []accessUnit
+	fail   bool
+	closed bool
+}
+
+func (d *testDecoder) Decode(nalus [][]byte) (image.Image, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.units = append(d.units, nalus)
+	if d.fail {
+		return nil, errors.New("corrupt picture")
+	}
+	return image.NewRGBA(image.Rect(0, 0, 16, 9)), nil
+}
+
+func (d *testDecoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	return nil
+}
+
+func (d *testDecoder) decoded() []accessUnit {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]accessUnit(nil), d.units...)
+}
+
+// registerTestDecoder registers decoder for the duration of the test.
+func registerTestDecoder(t *testing.T, decoder *testDecoder) {
+	t.Helper()
+	RegisterH264Decoder(func() (H264Decoder, error) { return decoder, nil })
+	t.Cleanup(func() { RegisterH264Decoder(nil) })
+}
+
+func rtp(sequence uint16, timestamp uint32, marker bool, payload []byte) rtpPacket {
+	return rtpPacket{payloadType: 96, sequence: sequence, timestamp: timestamp, marker: marker, payload: payload}
+}
+
+func stapA(nalus ...[]byte) []byte {
+	payload := []byte{nalSTAPA}
+	for _, nalu := range nalus {
+		payload = binary.BigEndian.AppendUint16(payload, uint16(len(nalu)))
+		payload = append(payload, nalu...)
+	}
+	return payload
+}
+
+// fuA splits a NAL unit into FU-A payloads of at most size bytes of data.
+func fuA(nalu []byte, size int) [][]byte {
+	var payloads [][]byte
+	for data := nalu[1:]; len(data) > 0; {
+		n := min(len(data), size)
+		header := nalu[0] & 0x1f
+		if len(payloads) == 0 {
+			header |= 0x80
+		}
+		if n == len(data) {
+			header |= 0x40
+		}
+		payloads = append(payloads, append([]byte{nalu[0]&0xe0 | nalFUA, header}, data[:n]...))
+		data = data[n:]
+	}
+	return payloads
+}
+
+func TestH264Depacketizer(t *testing.T) {
+	d := &h264Depacketizer{}
+
+	assert.Empty(t, d.push(rtp(1, 1000, false, stapA(testSPS, testPPS))))
+	fragments := fuA(testIDR, 4)
+	require.Len(t, fragments, 3)
+	assert.Empty(t, d.push(rtp(2, 1000, false, fragments[0])))
+	assert.Empty(t, d.push(rtp(3, 1000, false, fragments[1])))
+	assert.Equal(t, []accessUnit{{testSPS, testPPS, testIDR}}, d.push(rtp(4, 1000, true, fragments[2])))
+
+	assert.Equal(t, []accessUnit{{testP}}, d.push(rtp(5, 4000, true, testP)))
+
+	// Without a marker, the access unit ends when the timestamp changes.
+	assert.Empty(t, d.push(rtp(6, 7000, false, testP)))
+	assert.Equal(t, []accessUnit{{testP}, {testP}}, d.push(rtp(7, 10000, true, testP)))
+
+	// A lost packet drops the access unit it belonged to.
+	assert.Empty(t, d.push(rtp(8, 13000, false, fragments[0])))
+	assert.Equal(t, []accessUnit{nil}, d.push(rtp(10, 13000, true, fragments[2])))
+	assert.Equal(t, []accessUnit{{testP}}, d.push(rtp(11, 16000, true, testP)))
+}
+
+func TestH264Depacketizer_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{name: "truncated STAP-A", payload: append(stapA(testSPS), 0x00)},
+		{name: "STAP-A size past the end", payload: []byte{nalSTAPA, 0x00, 0x10, 0x67}},
+		{name: "FU-A without start", payload: fuA(testIDR, 4)[1]},
+		{name: "FU-B", payload: []byte{29, 0x85, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &h264Depacketizer{}
+			assert.Equal(t, []accessUnit{nil}, d.push(rtp(1, 1000, true, tt.payload)))
+		})
+	}
+}
+
+func TestH264Pictures(t *testing.T) {
+	decoder := &testDecoder{}
+	pictures := &h264Pictures{decoder: decoder, sps: testSPS, pps: testPPS}
+
+	// Decoding starts at a keyframe, with the parameter sets of the session
+	// description.
+	img, err := pictures.decode(accessUnit{testP})
+	assert.NoError(t, err)
+	assert.Nil(t, img)
+	img, err = pictures.decode(accessUnit{testIDR})
+	assert.NoError(t, err)
+	assert.NotNil(t, img)
+	img, err = pictures.decode(accessUnit{testP})
+	assert.NoError(t, err)
+	assert.NotNil(t, img)
+
+	// After a lost access unit, it starts over at the next keyframe.
+	img, err = pictures.decode(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, img)
+	img, err = pictures.decode(accessUnit{testP})
+	assert.NoError(t, err)
+	assert.Nil(t, img)
+
+	// Parameter sets repeated by the stream are not added again.
+	_, err = pictures.decode(accessUnit{testSPS, testPPS, testIDR})
+	assert.NoError(t, err)
+
+	decoder.fail = true
+	_, err = pictures.decode(accessUnit{testP})
+	assert.Error(t, err)
+	decoder.fail = false
+	img, err = pictures.decode(accessUnit{testP})
+	assert.NoError(t, err)
+	assert.Nil(t, img)
+
+	assert.Equal(t, []accessUnit{
+		{testSPS, testPPS, testIDR},
+		{testP},
+		{testSPS, testPPS, testIDR},
+		{testP},
+	}, decoder.decoded())
+}
+
+func TestRegisterH264Decoder(t *testing.T) {
+	assert.False(t, HasH264Decoder())
+	_, err := h264Decoder()
+	assert.ErrorIs(t, err, ErrNoDecoder)
+
+	decoder := &testDecoder{}
+	registerTestDecoder(t, decoder)
+	assert.True(t, HasH264Decoder())
+	got, err := h264Decoder()
+	require.NoError(t, err)
+	assert.Same(t, decoder, got)
+}
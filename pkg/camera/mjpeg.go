@@ -0,0 +1,138 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const mjpegBoundary = "frame"
+
+// MJPEGHandler relays camera frames to HTTP clients as an MJPEG stream
+// (multipart/x-mixed-replace), which browsers display in an <img> tag. All clients
+// share a single stream from the source, opened for the first client and closed
+// after the last one leaves.
+type MJPEGHandler struct {
+	source func(context.Context) <-chan Frame
+
+	mu      sync.Mutex
+	viewers map[chan Frame]struct{}
+	cancel  context.CancelFunc
+}
+
+// NewMJPEGHandler returns a handler relaying the frames of source, such as
+// Client.Stream.
+func NewMJPEGHandler(source func(context.Context) <-chan Frame) *MJPEGHandler {
+	return &MJPEGHandler{
+		source:  source,
+		viewers: make(map[chan Frame]struct{}),
+	}
+}
+
+func (h *MJPEGHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	frames := h.subscribe()
+	defer h.unsubscribe(frames)
+
+	started := false
+	for {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				if !started {
+					http.Error(w, "camera unavailable", http.StatusServiceUnavailable)
+				}
+				return
+			}
+
+			if !started {
+				w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+				w.Header().Set("Cache-Control", "no-cache")
+				started = true
+			}
+			if err := writePart(w, frame); err != nil {
+				return
+			}
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writePart(w http.ResponseWriter, frame Frame) error {
+	_, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", mjpegBoundary, len(frame.Image))
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(frame.Image); err != nil {
+		return err
+	}
+	_, err = w.Write([]byte("\r\n"))
+	return err
+}
+
+// subscribe registers a viewer, starting the source stream for the first one.
+func (h *MJPEGHandler) subscribe() chan Frame {
+	frames := make(chan Frame, 1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.viewers[frames] = struct{}{}
+	if h.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		h.cancel = cancel
+		go h.relay(ctx, h.source(ctx))
+	}
+	return frames
+}
+
+// unsubscribe removes a viewer, stopping the source stream after the last one.
+func (h *MJPEGHandler) unsubscribe(frames chan Frame) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.viewers[frames]; !ok {
+		return
+	}
+	delete(h.viewers, frames)
+	if len(h.viewers) == 0 && h.cancel != nil {
+		h.cancel()
+		h.cancel = nil
+	}
+}
+
+// relay forwards the frames of the source to every viewer, dropping frames for
+// viewers that have not consumed the previous one. When the source ends, viewers are
+// disconnected so that the next one starts it again.
+func (h *MJPEGHandler) relay(ctx context.Context, source <-chan Frame) {
+	for frame := range source {
+		if ctx.Err() != nil {
+			continue
+		}
+		h.mu.Lock()
+		for viewer := range h.viewers {
+			select {
+			case viewer <- frame:
+			default:
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ctx.Err() != nil {
+		// Stopped by unsubscribe, a new stream may already be running.
+		return
+	}
+	for viewer := range h.viewers {
+		close(viewer)
+		delete(h.viewers, viewer)
+	}
+	h.cancel()
+	h.cancel = nil
+}
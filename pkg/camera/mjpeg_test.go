@@ -0,0 +1,105 @@
+package camera
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSource emits a frame every few milliseconds and counts the running streams.
+type testSource struct {
+	running atomic.Int32
+	started atomic.Int32
+}
+
+func (s *testSource) stream(ctx context.Context) <-chan Frame {
+	frames := make(chan Frame)
+	s.running.Add(1)
+	s.started.Add(1)
+
+	go func() {
+		defer s.running.Add(-1)
+		defer close(frames)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case frames <- Frame{Image: jpegFrame("frame"), Time: time.Now()}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return frames
+}
+
+func readParts(t *testing.T, resp *http.Response, n int) {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/x-mixed-replace", mediaType)
+
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	for range n {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, "image/jpeg", part.Header.Get("Content-Type"))
+		image, err := io.ReadAll(part)
+		require.NoError(t, err)
+		assert.Equal(t, jpegFrame("frame"), image)
+	}
+}
+
+func TestMJPEGHandler(t *testing.T) {
+	source := &testSource{}
+	server := httptest.NewServer(NewMJPEGHandler(source.stream))
+	defer server.Close()
+
+	first, err := http.Get(server.URL)
+	require.NoError(t, err)
+	second, err := http.Get(server.URL)
+	require.NoError(t, err)
+
+	readParts(t, first, 3)
+	readParts(t, second, 3)
+	assert.Equal(t, int32(1), source.started.Load())
+
+	first.Body.Close()
+	second.Body.Close()
+	assert.Eventually(t, func() bool { return source.running.Load() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	third, err := http.Get(server.URL)
+	require.NoError(t, err)
+	readParts(t, third, 1)
+	third.Body.Close()
+	assert.Equal(t, int32(2), source.started.Load())
+}
+
+func TestMJPEGHandler_SourceUnavailable(t *testing.T) {
+	closed := func(context.Context) <-chan Frame {
+		frames := make(chan Frame)
+		close(frames)
+		return frames
+	}
+	server := httptest.NewServer(NewMJPEGHandler(closed))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -0,0 +1,683 @@
+package camera
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// X1 series printers serve their camera over RTSPS on port 322, as H.264 over RTP
+// interleaved on the RTSP connection. RTSPClient implements the part of RTSP
+// (RFC 2326) the printer needs: DESCRIBE, SETUP, PLAY and keep-alives, with Basic
+// or Digest authentication. The pictures of the registered H264Decoder are JPEG
+// encoded, so that they are used like the frames of the P1 and A1 series.
+
+const (
+	DefaultRTSPPort = 322
+
+	rtspUserAgent        = "bambulabs_cloud_api"
+	rtspSessionTimeout   = 60 * time.Second // Assumed when the server does not say
+	maxRTSPResponseSize  = 64 << 10
+	rtspTransport        = "RTP/AVP/TCP;unicast;interleaved=0-1"
+	rtspInterleavedMagic = '$'
+	rtpHeaderSize        = 12
+	jpegQuality          = 80
+)
+
+type RTSPConfig struct {
+	URL       string // rtsps URL of the stream, including the credentials
+	Timeout   time.Duration
+	TLSConfig *tls.Config
+}
+
+// RTSPClient reads the camera of an X1 series printer on the LAN. It needs an H.264
+// decoder, see RegisterH264Decoder.
+type RTSPClient struct {
+	config *RTSPConfig
+}
+
+func NewRTSPClient(config *RTSPConfig) *RTSPClient {
+	return &RTSPClient{config: config}
+}
+
+// Snapshot connects to the camera and returns its first picture, which comes with
+// the first keyframe.
+func (c *RTSPClient) Snapshot(ctx context.Context) (Frame, error) {
+	var (
+		frame     Frame
+		encodeErr error
+	)
+	err := c.play(ctx, func(img image.Image) bool {
+		frame, encodeErr = encodeFrame(img)
+		return false
+	})
+	if err != nil {
+		return Frame{}, err
+	}
+	return frame, encodeErr
+}
+
+// Stream sends the pictures of the camera on the returned channel until ctx is done,
+// reconnecting when the connection drops. Pictures are skipped while the receiver
+// is not ready, so that they are only JPEG encoded when needed. The channel is
+// closed right away when no decoder is registered.
+func (c *RTSPClient) Stream(ctx context.Context) <-chan Frame {
+	frames := make(chan Frame, 1)
+
+	go func() {
+		defer close(frames)
+		for {
+			// Frames are only sent from here, so there is room once it is empty.
+			err := c.play(ctx, func(img image.Image) bool {
+				if len(frames) > 0 {
+					return true
+				}
+				frame, err := encodeFrame(img)
+				if err != nil {
+					log.Printf("Failed to encode camera frame of %s: %v", c.host(), err)
+					return true
+				}
+				frames <- frame
+				return true
+			})
+			if errors.Is(err, ErrNoDecoder) {
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Camera stream of %s interrupted: %v", c.host(), err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reconnectDelay):
+			}
+		}
+	}()
+
+	return frames
+}
+
+// play streams the camera, calling picture with each decoded picture until it
+// returns false or ctx is done.
+func (c *RTSPClient) play(ctx context.Context, picture func(image.Image) bool) error {
+	decoder, err := h264Decoder()
+	if err != nil {
+		return err
+	}
+	if closer, ok := decoder.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+	stop := context.AfterFunc(ctx, func() { conn.conn.Close() })
+	defer stop()
+
+	session, err := conn.setup()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	keepAlive := make(chan struct{})
+	defer close(keepAlive)
+	go conn.keepAlive(session.timeout/2, keepAlive)
+
+	pictures := &h264Pictures{decoder: decoder, sps: session.track.sps, pps: session.track.pps}
+	depacketizer := &h264Depacketizer{}
+	for {
+		packet, err := conn.readPacket(session.channel)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if packet.payloadType != session.track.payloadType {
+			continue
+		}
+
+		for _, unit := range depacketizer.push(packet) {
+			img, err := pictures.decode(unit)
+			if err != nil {
+				log.Printf("Failed to decode camera picture of %s: %v", c.host(), err)
+				continue
+			}
+			if img != nil && !picture(img) {
+				return nil
+			}
+		}
+	}
+}
+
+// host returns the host of the camera, for logs: the URL holds the access code.
+func (c *RTSPClient) host() string {
+	if u, err := url.Parse(c.config.URL); err == nil {
+		return u.Hostname()
+	}
+	return "camera"
+}
+
+func (c *RTSPClient) dial(ctx context.Context) (*rtspConn, error) {
+	u, err := url.Parse(c.config.URL)
+	if err != nil || u.Scheme != "rtsps" || u.Host == "" {
+		// The error would include the URL and its credentials.
+		return nil, errors.New("invalid camera URL")
+	}
+	user := u.User
+	u.User = nil
+
+	port := u.Port()
+	if port == "" {
+		port = strconv.Itoa(DefaultRTSPPort)
+	}
+	timeout := c.config.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    c.config.TLSConfig,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to camera: %w", err)
+	}
+
+	return &rtspConn{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		url:     u,
+		user:    user,
+		timeout: timeout,
+	}, nil
+}
+
+func encodeFrame(img image.Image) (Frame, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return Frame{}, err
+	}
+	return Frame{Image: buf.Bytes(), Time: time.Now()}, nil
+}
+
+// rtspConn is an RTSP connection. Requests are written and answered one at a time
+// until PLAY; the RTP packets are then read along with the replies to keep-alives,
+// which are skipped.
+type rtspConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	url     *url.URL // Without the credentials
+	user    *url.Userinfo
+	timeout time.Duration
+
+	mu      sync.Mutex // Held while writing a request
+	cseq    int
+	auth    *rtspChallenge
+	session string
+}
+
+type rtspResponse struct {
+	status int
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// rtspSession describes a playing stream.
+type rtspSession struct {
+	track   h264Track
+	channel byte // Interleaved channel of the RTP packets
+	timeout time.Duration
+}
+
+// setup describes the stream, sets up its H.264 track and starts playing.
+func (c *rtspConn) setup() (rtspSession, error) {
+	var session rtspSession
+
+	response, err := c.request("DESCRIBE", c.url.String(), textproto.MIMEHeader{"Accept": {"application/sdp"}})
+	if err != nil {
+		return session, err
+	}
+	base := response.header.Get("Content-Base")
+	if base == "" {
+		base = c.url.String()
+	}
+	session.track, err = parseSDP(response.body)
+	if err != nil {
+		return session, err
+	}
+
+	response, err = c.request("SETUP", controlURL(base, session.track.control), textproto.MIMEHeader{"Transport": {rtspTransport}})
+	if err != nil {
+		return session, err
+	}
+	id, timeout := parseSession(response.header.Get("Session"))
+	if id == "" {
+		return session, errors.New("SETUP failed: no session")
+	}
+	c.mu.Lock()
+	c.session = id
+	c.mu.Unlock()
+	session.timeout = timeout
+	session.channel = interleavedChannel(response.header.Get("Transport"))
+
+	if _, err := c.request("PLAY", base, textproto.MIMEHeader{"Range": {"npt=0.000-"}}); err != nil {
+		return session, err
+	}
+	return session, nil
+}
+
+// request sends a request and reads its response, authenticating if the server
+// asks to.
+func (c *rtspConn) request(method, uri string, header textproto.MIMEHeader) (*rtspResponse, error) {
+	for authenticated := false; ; authenticated = true {
+		if err := c.write(method, uri, header); err != nil {
+			return nil, fmt.Errorf("%s failed: %w", method, err)
+		}
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+		response, err := c.readResponse()
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %w", method, err)
+		}
+
+		if response.status == 401 && !authenticated && c.user != nil {
+			auth := parseChallenge(response.header.Values("Www-Authenticate"))
+			if auth == nil {
+				return nil, fmt.Errorf("%s failed: unsupported authentication", method)
+			}
+			c.mu.Lock()
+			c.auth = auth
+			c.mu.Unlock()
+			continue
+		}
+		if response.status != 200 {
+			return nil, fmt.Errorf("%s failed with status %d", method, response.status)
+		}
+		return response, nil
+	}
+}
+
+func (c *rtspConn) write(method, uri string, header textproto.MIMEHeader) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cseq++
+	var request strings.Builder
+	fmt.Fprintf(&request, "%s %s RTSP/1.0\r\nCSeq: %d\r\nUser-Agent: %s\r\n", method, uri, c.cseq, rtspUserAgent)
+	if c.auth != nil {
+		fmt.Fprintf(&request, "Authorization: %s\r\n", c.auth.authorization(c.user, method, uri))
+	}
+	if c.session != "" {
+		fmt.Fprintf(&request, "Session: %s\r\n", c.session)
+	}
+	for key, values := range header {
+		for _, value := range values {
+			fmt.Fprintf(&request, "%s: %s\r\n", key, value)
+		}
+	}
+	request.WriteString("\r\n")
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := io.WriteString(c.conn, request.String())
+	return err
+}
+
+func (c *rtspConn) readResponse() (*rtspResponse, error) {
+	reader := textproto.NewReader(c.reader)
+	line, err := reader.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	proto, status, _ := strings.Cut(line, " ")
+	status, _, _ = strings.Cut(status, " ")
+	code, err := strconv.Atoi(status)
+	if !strings.HasPrefix(proto, "RTSP/") || err != nil {
+		return nil, fmt.Errorf("invalid response %q", line)
+	}
+
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	response := &rtspResponse{status: code, header: header}
+	if length := header.Get("Content-Length"); length != "" {
+		size, err := strconv.Atoi(length)
+		if err != nil || size < 0 || size > maxRTSPResponseSize {
+			return nil, fmt.Errorf("invalid content length %q", length)
+		}
+		response.body = make([]byte, size)
+		if _, err := io.ReadFull(c.reader, response.body); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// readPacket returns the next RTP packet of channel, skipping RTCP packets and the
+// replies to keep-alives.
+func (c *rtspConn) readPacket(channel byte) (rtpPacket, error) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+		first, err := c.reader.Peek(1)
+		if err != nil {
+			return rtpPacket{}, err
+		}
+		if first[0] != rtspInterleavedMagic {
+			if _, err := c.readResponse(); err != nil {
+				return rtpPacket{}, err
+			}
+			continue
+		}
+
+		var header [4]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			return rtpPacket{}, err
+		}
+		data := make([]byte, binary.BigEndian.Uint16(header[2:]))
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return rtpPacket{}, err
+		}
+		if header[1] != channel {
+			continue
+		}
+		if packet, err := parseRTP(data); err == nil {
+			return packet, nil
+		}
+	}
+}
+
+// keepAlive sends an OPTIONS request at every interval until done is closed, so
+// that the server does not end the session.
+func (c *rtspConn) keepAlive(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.write("OPTIONS", c.url.String(), nil); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// close ends the session before closing the connection.
+func (c *rtspConn) close() {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session != "" {
+		c.write("TEARDOWN", c.url.String(), nil)
+	}
+	c.conn.Close()
+}
+
+// rtspChallenge is the authentication requested by the server.
+type rtspChallenge struct {
+	digest bool
+	realm  string
+	nonce  string
+	opaque string
+	qop    bool // qop=auth
+	count  int  // Nonce count, for qop=auth
+}
+
+// parseChallenge returns the challenge of WWW-Authenticate headers, preferring
+// Digest over Basic, or nil if neither is offered.
+func parseChallenge(values []string) *rtspChallenge {
+	var challenge *rtspChallenge
+	for _, value := range values {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(value), " ")
+		switch {
+		case strings.EqualFold(scheme, "Digest"):
+			fields := parseAuthParams(params)
+			challenge = &rtspChallenge{
+				digest: true,
+				realm:  fields["realm"],
+				nonce:  fields["nonce"],
+				opaque: fields["opaque"],
+			}
+			for _, qop := range strings.Split(fields["qop"], ",") {
+				if strings.TrimSpace(qop) == "auth" {
+					challenge.qop = true
+				}
+			}
+			return challenge
+		case strings.EqualFold(scheme, "Basic"):
+			challenge = &rtspChallenge{}
+		}
+	}
+	return challenge
+}
+
+// parseAuthParams parses the comma separated key=value pairs of a challenge,
+// values being quoted or not.
+func parseAuthParams(params string) map[string]string {
+	fields := make(map[string]string)
+	for {
+		params = strings.TrimLeft(params, " ,")
+		key, rest, ok := strings.Cut(params, "=")
+		if !ok {
+			return fields
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				b.WriteByte(rest[i])
+			}
+			value, params = b.String(), rest[min(i+1, len(rest)):]
+		} else {
+			value, params, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		fields[key] = value
+	}
+}
+
+// authorization returns the Authorization header of a request, see RFC 2617.
+func (a *rtspChallenge) authorization(user *url.Userinfo, method, uri string) string {
+	password, _ := user.Password()
+	if !a.digest {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password))
+	}
+
+	ha1 := md5Hex(user.Username() + ":" + a.realm + ":" + password)
+	ha2 := md5Hex(method + ":" + uri)
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`, user.Username(), a.realm, a.nonce, uri)
+	if a.qop {
+		a.count++
+		nc := fmt.Sprintf("%08x", a.count)
+		cnonce := make([]byte, 8)
+		rand.Read(cnonce)
+		header += fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%x", response="%s"`, nc, cnonce,
+			md5Hex(ha1+":"+a.nonce+":"+nc+":"+hex.EncodeToString(cnonce)+":auth:"+ha2))
+	} else {
+		header += fmt.Sprintf(`, response="%s"`, md5Hex(ha1+":"+a.nonce+":"+ha2))
+	}
+	if a.opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, a.opaque)
+	}
+	return header
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// h264Track is the H.264 video track of a session description.
+type h264Track struct {
+	control     string
+	payloadType byte
+	sps, pps    []byte // Parameter sets from sprop-parameter-sets
+}
+
+// parseSDP returns the first H.264 video track of a session description (RFC 4566).
+func parseSDP(sdp []byte) (h264Track, error) {
+	var (
+		track   h264Track
+		current *h264Track
+		format  string // Payload type of the current media
+	)
+	for _, line := range strings.Split(string(sdp), "\n") {
+		line = strings.TrimSpace(line)
+		key, value, _ := strings.Cut(line, "=")
+
+		switch {
+		case key == "m":
+			if current != nil && current.payloadType != 0 {
+				return *current, nil
+			}
+			current = nil
+			if fields := strings.Fields(value); len(fields) >= 4 && fields[0] == "video" {
+				current, format = &h264Track{}, fields[3]
+			}
+		case current == nil || key != "a":
+		case strings.HasPrefix(value, "rtpmap:"):
+			id, encoding, _ := strings.Cut(strings.TrimPrefix(value, "rtpmap:"), " ")
+			payloadType, err := strconv.Atoi(id)
+			if id == format && err == nil && strings.HasPrefix(strings.ToUpper(encoding), "H264/") {
+				current.payloadType = byte(payloadType)
+			}
+		case strings.HasPrefix(value, "control:"):
+			current.control = strings.TrimPrefix(value, "control:")
+		case strings.HasPrefix(value, "fmtp:"+format+" "):
+			params := strings.TrimPrefix(value, "fmtp:"+format+" ")
+			for _, param := range strings.Split(params, ";") {
+				name, sets, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if name != "sprop-parameter-sets" {
+					continue
+				}
+				for _, set := range strings.Split(sets, ",") {
+					nalu, err := base64.StdEncoding.DecodeString(set)
+					if err != nil || len(nalu) == 0 {
+						continue
+					}
+					switch nalu[0] & 0x1f {
+					case nalSPS:
+						current.sps = nalu
+					case nalPPS:
+						current.pps = nalu
+					}
+				}
+			}
+		}
+	}
+	if current != nil && current.payloadType != 0 {
+		return *current, nil
+	}
+	return track, errors.New("no H.264 video track")
+}
+
+// controlURL resolves the control attribute of a track against the base URL.
+func controlURL(base, control string) string {
+	switch {
+	case control == "" || control == "*":
+		return base
+	case strings.Contains(control, "://"):
+		return control
+	default:
+		return strings.TrimSuffix(base, "/") + "/" + control
+	}
+}
+
+// parseSession returns the id and the timeout of a Session header.
+func parseSession(header string) (string, time.Duration) {
+	id, params, _ := strings.Cut(header, ";")
+	timeout := rtspSessionTimeout
+	for _, param := range strings.Split(params, ";") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(param), "timeout="); ok {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				timeout = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return strings.TrimSpace(id), timeout
+}
+
+// interleavedChannel returns the channel of the RTP packets from a Transport
+// header, 0 as requested if the server does not say.
+func interleavedChannel(transport string) byte {
+	for _, param := range strings.Split(transport, ";") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(param), "interleaved="); ok {
+			first, _, _ := strings.Cut(value, "-")
+			if channel, err := strconv.Atoi(first); err == nil && channel >= 0 && channel < 256 {
+				return byte(channel)
+			}
+		}
+	}
+	return 0
+}
+
+type rtpPacket struct {
+	payloadType byte
+	marker      bool
+	sequence    uint16
+	timestamp   uint32
+	payload     []byte
+}
+
+// parseRTP parses an RTP packet (RFC 3550).
+func parseRTP(data []byte) (rtpPacket, error) {
+	if len(data) < rtpHeaderSize || data[0]>>6 != 2 {
+		return rtpPacket{}, errors.New("invalid RTP packet")
+	}
+	packet := rtpPacket{
+		payloadType: data[1] & 0x7f,
+		marker:      data[1]&0x80 != 0,
+		sequence:    binary.BigEndian.Uint16(data[2:]),
+		timestamp:   binary.BigEndian.Uint32(data[4:]),
+	}
+
+	offset := rtpHeaderSize + 4*int(data[0]&0x0f)
+	if data[0]&0x10 != 0 {
+		if len(data) < offset+4 {
+			return rtpPacket{}, errors.New("invalid RTP packet")
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+	end := len(data)
+	if data[0]&0x20 != 0 && end > 0 {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return rtpPacket{}, errors.New("invalid RTP packet")
+	}
+	packet.payload = data[offset:end]
+	return packet, nil
+}
@@ -0,0 +1,290 @@
+package camera
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRealm = "LIVE555 Streaming Media"
+	testNonce = "0a1b2c3d"
+)
+
+// newTestRTSPCamera starts a TLS server behaving like the RTSP server of an X1
+// series printer: it requires Digest authentication, describes a single H.264
+// track whose parameter sets are only in the session description, and once
+// playing sends a keyframe followed by P frames, with RTCP packets in between.
+func newTestRTSPCamera(t *testing.T, accessCode string) *RTSPConfig {
+	t.Helper()
+	listener := newTestListener(t)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestRTSP(conn, listener.Addr().String(), accessCode)
+		}
+	}()
+
+	return &RTSPConfig{
+		URL:       fmt.Sprintf("rtsps://bblp:%s@%s/streaming/live/1", accessCode, listener.Addr()),
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		Timeout:   5 * time.Second,
+	}
+}
+
+func serveTestRTSP(conn net.Conn, host, accessCode string) {
+	defer conn.Close()
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	base := "rtsps://" + host + "/streaming/live/1/"
+	sdp := fmt.Sprintf("v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=Session\r\nt=0 0\r\na=control:*\r\n"+
+		"m=audio 0 RTP/AVP 0\r\na=control:track0\r\n"+
+		"m=video 0 RTP/AVP 96\r\na=rtpmap:96 H264/90000\r\n"+
+		"a=fmtp:96 packetization-mode=1;profile-level-id=640028;sprop-parameter-sets=%s,%s\r\na=control:track1\r\n",
+		base64.StdEncoding.EncodeToString(testSPS), base64.StdEncoding.EncodeToString(testPPS))
+
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		method, uri, _ := strings.Cut(line, " ")
+		uri, _, _ = strings.Cut(uri, " ")
+		header, err := reader.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+
+		reply := func(status string, headers ...string) {
+			fmt.Fprintf(conn, "RTSP/1.0 %s\r\nCSeq: %s\r\n", status, header.Get("CSeq"))
+			for _, h := range headers {
+				fmt.Fprintf(conn, "%s\r\n", h)
+			}
+			io.WriteString(conn, "\r\n")
+		}
+
+		if !testDigestValid(header.Get("Authorization"), method, accessCode) {
+			reply("401 Unauthorized", fmt.Sprintf(`WWW-Authenticate: Digest realm="%s", nonce="%s", qop="auth"`, testRealm, testNonce))
+			continue
+		}
+
+		switch method {
+		case "DESCRIBE":
+			reply("200 OK", "Content-Base: "+base, "Content-Type: application/sdp", fmt.Sprintf("Content-Length: %d", len(sdp)))
+			io.WriteString(conn, sdp)
+		case "SETUP":
+			if uri != base+"track1" {
+				reply("404 Not Found")
+				continue
+			}
+			reply("200 OK", "Session: 12345678;timeout=65", "Transport: RTP/AVP/TCP;unicast;interleaved=2-3")
+		case "PLAY":
+			if header.Get("Session") != "12345678" {
+				reply("454 Session Not Found")
+				continue
+			}
+			reply("200 OK", "Session: 12345678")
+			go writeTestStream(conn)
+		case "TEARDOWN":
+			reply("200 OK")
+			return
+		default:
+			reply("200 OK")
+		}
+	}
+}
+
+// testDigestValid checks the Digest authorization of a request.
+func testDigestValid(authorization, method, accessCode string) bool {
+	scheme, params, _ := strings.Cut(authorization, " ")
+	if scheme != "Digest" {
+		return false
+	}
+	fields := parseAuthParams(params)
+	ha1 := md5Hex("bblp:" + testRealm + ":" + accessCode)
+	ha2 := md5Hex(method + ":" + fields["uri"])
+	want := md5Hex(ha1 + ":" + testNonce + ":" + fields["nc"] + ":" + fields["cnonce"] + ":auth:" + ha2)
+	return fields["username"] == "bblp" && fields["qop"] == "auth" && fields["response"] == want
+}
+
+// writeTestStream sends a keyframe split into FU-A packets, then P frames, on
+// interleaved channel 2, with RTCP packets on channel 3.
+func writeTestStream(conn net.Conn) {
+	var sequence uint16
+	write := func(channel byte, data []byte) bool {
+		packet := append([]byte{'$', channel, 0, 0}, data...)
+		binary.BigEndian.PutUint16(packet[2:], uint16(len(data)))
+		_, err := conn.Write(packet)
+		return err == nil
+	}
+	writeRTP := func(timestamp uint32, marker bool, payload []byte) bool {
+		header := make([]byte, rtpHeaderSize)
+		header[0] = 0x80
+		header[1] = 96
+		if marker {
+			header[1] |= 0x80
+		}
+		sequence++
+		binary.BigEndian.PutUint16(header[2:], sequence)
+		binary.BigEndian.PutUint32(header[4:], timestamp)
+		return write(2, append(header, payload...))
+	}
+
+	fragments := fuA(testIDR, 4)
+	for i, fragment := range fragments {
+		if !writeRTP(0, i == len(fragments)-1, fragment) {
+			return
+		}
+	}
+	for i := uint32(1); ; i++ {
+		if !write(3, []byte{0x80, 200, 0, 6}) || !writeRTP(i*3000, true, testP) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRTSPClient_Snapshot(t *testing.T) {
+	decoder := &testDecoder{}
+	registerTestDecoder(t, decoder)
+	client := NewRTSPClient(newTestRTSPCamera(t, "12345678"))
+
+	frame, err := client.Snapshot(context.Background())
+	require.NoError(t, err)
+	img, err := jpeg.Decode(bytes.NewReader(frame.Image))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 16, 9), img.Bounds())
+	assert.False(t, frame.Time.IsZero())
+
+	// The keyframe comes with the parameter sets of the session description.
+	units := decoder.decoded()
+	require.NotEmpty(t, units)
+	assert.Equal(t, accessUnit{testSPS, testPPS, testIDR}, units[0])
+	assert.True(t, decoder.closed)
+}
+
+func TestRTSPClient_SnapshotWrongAccessCode(t *testing.T) {
+	registerTestDecoder(t, &testDecoder{})
+	config := newTestRTSPCamera(t, "12345678")
+	config.URL = strings.Replace(config.URL, "12345678", "wrong", 1)
+
+	_, err := NewRTSPClient(config).Snapshot(context.Background())
+	assert.ErrorContains(t, err, "status 401")
+	assert.NotContains(t, err.Error(), "wrong")
+}
+
+func TestRTSPClient_NoDecoder(t *testing.T) {
+	client := NewRTSPClient(newTestRTSPCamera(t, "12345678"))
+
+	_, err := client.Snapshot(context.Background())
+	assert.ErrorIs(t, err, ErrNoDecoder)
+
+	frames := client.Stream(context.Background())
+	select {
+	case _, ok := <-frames:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed")
+	}
+}
+
+func TestRTSPClient_MJPEGRelay(t *testing.T) {
+	registerTestDecoder(t, &testDecoder{})
+	client := NewRTSPClient(newTestRTSPCamera(t, "12345678"))
+	server := httptest.NewServer(NewMJPEGHandler(client.Stream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/x-mixed-replace", mediaType)
+
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	for range 3 {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		img, err := jpeg.Decode(part)
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 16, 9), img.Bounds())
+	}
+}
+
+func TestParseSDP(t *testing.T) {
+	track, err := parseSDP([]byte("v=0\nm=video 0 RTP/AVP 97\na=rtpmap:97 H264/90000\na=fmtp:97 sprop-parameter-sets=" +
+		base64.StdEncoding.EncodeToString(testSPS) + "," + base64.StdEncoding.EncodeToString(testPPS) +
+		"\na=control:rtsps://192.168.1.20/streaming/live/1/track1\nm=video 0 RTP/AVP 98\na=rtpmap:98 H265/90000\n"))
+	require.NoError(t, err)
+	assert.Equal(t, h264Track{control: "rtsps://192.168.1.20/streaming/live/1/track1", payloadType: 97, sps: testSPS, pps: testPPS}, track)
+
+	_, err = parseSDP([]byte("v=0\nm=video 0 RTP/AVP 98\na=rtpmap:98 H265/90000\n"))
+	assert.Error(t, err)
+}
+
+func TestControlURL(t *testing.T) {
+	base := "rtsps://192.168.1.20/streaming/live/1/"
+	assert.Equal(t, base, controlURL(base, "*"))
+	assert.Equal(t, base+"track1", controlURL(base, "track1"))
+	assert.Equal(t, base+"track1", controlURL(strings.TrimSuffix(base, "/"), "track1"))
+	assert.Equal(t, "rtsps://other/track1", controlURL(base, "rtsps://other/track1"))
+}
+
+func TestParseSession(t *testing.T) {
+	id, timeout := parseSession("12345678;timeout=65")
+	assert.Equal(t, "12345678", id)
+	assert.Equal(t, 65*time.Second, timeout)
+
+	id, timeout = parseSession("ABCDEF")
+	assert.Equal(t, "ABCDEF", id)
+	assert.Equal(t, rtspSessionTimeout, timeout)
+
+	assert.Equal(t, byte(2), interleavedChannel("RTP/AVP/TCP;unicast;interleaved=2-3"))
+	assert.Equal(t, byte(0), interleavedChannel("RTP/AVP/TCP;unicast"))
+}
+
+func TestParseChallenge(t *testing.T) {
+	challenge := parseChallenge([]string{`Basic realm="x"`, `Digest realm="a \"b\"", nonce="n1", opaque=o1, qop="auth,auth-int"`})
+	require.NotNil(t, challenge)
+	assert.Equal(t, &rtspChallenge{digest: true, realm: `a "b"`, nonce: "n1", opaque: "o1", qop: true}, challenge)
+
+	assert.Equal(t, &rtspChallenge{}, parseChallenge([]string{`Basic realm="x"`}))
+	assert.Nil(t, parseChallenge([]string{`Bearer`}))
+}
+
+func TestParseRTP(t *testing.T) {
+	// Version 2 with padding, an extension and a contributing source.
+	data := []byte{0xb1, 0xe0, 0x00, 0x07, 0x00, 0x00, 0x0b, 0xb8, 0, 0, 0, 1, 0, 0, 0, 2,
+		0xbe, 0xde, 0x00, 0x01, 1, 2, 3, 4, 0x41, 0x9a, 0x00, 0x02}
+	packet, err := parseRTP(data)
+	require.NoError(t, err)
+	assert.Equal(t, rtpPacket{payloadType: 96, marker: true, sequence: 7, timestamp: 3000, payload: []byte{0x41, 0x9a}}, packet)
+
+	_, err = parseRTP([]byte{0x80, 0x60})
+	assert.Error(t, err)
+	_, err = parseRTP(append([]byte{0x40}, make([]byte, 12)...))
+	assert.Error(t, err)
+}
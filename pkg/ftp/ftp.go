@@ -0,0 +1,295 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/secsy/goftp"
+)
+
+const (
+	DefaultPort     = 990
+	DefaultUsername = "bblp"
+	defaultTimeout  = 10 * time.Second
+
+	// Printers only accept a couple of simultaneous sessions.
+	connectionsPerHost = 2
+)
+
+// ErrNotSupported is returned when the printer does not implement a command.
+var ErrNotSupported = errors.New("not supported by the printer")
+
+// ClientConfig configures the connection to the FTPS server of a printer on the LAN,
+// which uses implicit TLS and the same credentials as its MQTT broker.
+type ClientConfig struct {
+	Host       string
+	Port       int // Defaults to DefaultPort
+	Username   string
+	AccessCode string
+	Timeout    time.Duration
+	TLSConfig  *tls.Config // Used for both the control and the data connections
+}
+
+type File struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	IsDir   bool      `json:"is_dir"`
+}
+
+// Progress reports how far a transfer got. Total is -1 when the size of the file is
+// not known in advance.
+type Progress struct {
+	Name        string
+	Transferred int64
+	Total       int64
+}
+
+// Percent returns the progress of the transfer between 0 and 100, or -1 if the total
+// size is unknown.
+func (p Progress) Percent() float64 {
+	if p.Total < 0 {
+		return -1
+	}
+	if p.Total == 0 {
+		return 100
+	}
+	return float64(p.Transferred) / float64(p.Total) * 100
+}
+
+// ProgressFunc is called every time a chunk of a file has been transferred.
+type ProgressFunc func(Progress)
+
+// Client manages the files stored on a printer. Connections are opened when needed
+// and kept open until Close.
+type Client struct {
+	config *ClientConfig
+	client *goftp.Client
+}
+
+func NewClient(config *ClientConfig) (*Client, error) {
+	port := config.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	username := config.Username
+	if username == "" {
+		username = DefaultUsername
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	tlsConfig := config.TLSConfig
+	if tlsConfig == nil {
+		return nil, errors.New("ftp: a TLS config is required")
+	}
+	tlsConfig = tlsConfig.Clone()
+	// The data connections must resume the TLS session of the control connection.
+	// Sessions are cached by server name, which would otherwise be the address of
+	// each data connection.
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = config.Host
+	}
+	if tlsConfig.ClientSessionCache == nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(connectionsPerHost)
+	}
+
+	client, err := goftp.DialConfig(goftp.Config{
+		User:               username,
+		Password:           config.AccessCode,
+		ConnectionsPerHost: connectionsPerHost,
+		Timeout:            timeout,
+		TLSConfig:          tlsConfig,
+		TLSMode:            goftp.TLSImplicit,
+	}, fmt.Sprintf("%s:%d", config.Host, port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create FTP client: %w", err)
+	}
+
+	return &Client{
+		config: config,
+		client: client,
+	}, nil
+}
+
+// Close closes all open connections.
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+// ListFiles lists the files and directories in dir, "/" being the root of the SD card.
+func (c *Client) ListFiles(dir string) ([]File, error) {
+	entries, err := c.client.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	files := make([]File, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, File{
+			Name:    entry.Name(),
+			Size:    entry.Size(),
+			ModTime: entry.ModTime(),
+			IsDir:   entry.IsDir(),
+		})
+	}
+	return files, nil
+}
+
+// Upload stores the content of r as name on the printer, replacing any existing file.
+// Cancelling ctx aborts the transfer.
+func (c *Client) Upload(ctx context.Context, name string, r io.Reader, progress ...ProgressFunc) error {
+	src := &progressReader{
+		ctx:      ctx,
+		reader:   r,
+		progress: progress,
+		current:  Progress{Name: name, Total: readerSize(r)},
+	}
+
+	if err := c.client.Store(name, src); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to upload %s: %w", name, ctx.Err())
+		}
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return nil
+}
+
+// Download writes the content of the file name to w. Cancelling ctx aborts the
+// transfer.
+func (c *Client) Download(ctx context.Context, name string, w io.Writer, progress ...ProgressFunc) error {
+	total := int64(-1)
+	if len(progress) > 0 {
+		if info, err := c.client.Stat(name); err == nil {
+			total = info.Size()
+		}
+	}
+
+	dest := &progressWriter{
+		ctx:      ctx,
+		writer:   w,
+		progress: progress,
+		current:  Progress{Name: name, Total: total},
+	}
+
+	if err := c.client.Retrieve(name, dest); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to download %s: %w", name, ctx.Err())
+		}
+		return fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	return nil
+}
+
+func (c *Client) Delete(name string) error {
+	if err := c.client.Delete(name); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", name, err)
+	}
+	return nil
+}
+
+func (c *Client) Rename(from, to string) error {
+	if err := c.client.Rename(from, to); err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", from, to, err)
+	}
+	return nil
+}
+
+// FreeSpace returns the number of bytes available in dir, using the AVBL command. It
+// returns ErrNotSupported if the printer does not implement it.
+func (c *Client) FreeSpace(dir string) (int64, error) {
+	conn, err := c.client.OpenRawConn()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query free space: %w", err)
+	}
+	defer conn.Close()
+
+	code, msg, err := conn.SendCommand("AVBL %s", dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query free space: %w", err)
+	}
+
+	switch {
+	case code == 213:
+	case code >= 500 && code <= 504:
+		return 0, ErrNotSupported
+	default:
+		return 0, fmt.Errorf("failed to query free space: %d %s", code, msg)
+	}
+
+	fields := strings.Fields(msg)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("failed to query free space: invalid reply %q", msg)
+	}
+	free, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query free space: invalid reply %q", msg)
+	}
+	return free, nil
+}
+
+// readerSize returns the number of bytes left in r, or -1 if it cannot tell.
+func readerSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Stat() (fs.FileInfo, error) }:
+		if info, err := r.Stat(); err == nil && info.Mode().IsRegular() {
+			return info.Size()
+		}
+	}
+	return -1
+}
+
+type progressReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	progress []ProgressFunc
+	current  Progress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.current.Transferred += int64(n)
+		for _, fn := range r.progress {
+			fn(r.current)
+		}
+	}
+	return n, err
+}
+
+type progressWriter struct {
+	ctx      context.Context
+	writer   io.Writer
+	progress []ProgressFunc
+	current  Progress
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := w.writer.Write(p)
+	if n > 0 {
+		w.current.Transferred += int64(n)
+		for _, fn := range w.progress {
+			fn(w.current)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,130 @@
+package ftp
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*Client, *testServer) {
+	t.Helper()
+	server, tlsConfig := newTestServer(t, "12345678")
+
+	client, err := NewClient(server.config(tlsConfig))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client, server
+}
+
+func TestClient_Upload(t *testing.T) {
+	client, server := newTestClient(t)
+	content := bytes.Repeat([]byte("G1 X10 Y10\n"), 10000)
+
+	var progress []Progress
+	err := client.Upload(context.Background(), "/cube.gcode", bytes.NewReader(content), func(p Progress) {
+		progress = append(progress, p)
+	})
+	require.NoError(t, err)
+
+	stored, ok := server.file("/cube.gcode")
+	require.True(t, ok)
+	assert.Equal(t, content, stored)
+
+	require.NotEmpty(t, progress)
+	last := progress[len(progress)-1]
+	assert.Equal(t, "/cube.gcode", last.Name)
+	assert.Equal(t, int64(len(content)), last.Transferred)
+	assert.Equal(t, int64(len(content)), last.Total)
+	assert.Equal(t, 100.0, last.Percent())
+}
+
+func TestClient_UploadCancelled(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := client.Upload(ctx, "/cube.gcode", strings.NewReader("G28\n"))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	stored, _ := server.file("/cube.gcode")
+	assert.Empty(t, stored)
+}
+
+func TestClient_Download(t *testing.T) {
+	client, server := newTestClient(t)
+	server.setFile("/model/cube.3mf", []byte("3mf content"))
+
+	var buf bytes.Buffer
+	var last Progress
+	err := client.Download(context.Background(), "/model/cube.3mf", &buf, func(p Progress) { last = p })
+	require.NoError(t, err)
+
+	assert.Equal(t, "3mf content", buf.String())
+	assert.Equal(t, int64(11), last.Transferred)
+
+	err = client.Download(context.Background(), "/missing.3mf", &buf)
+	assert.Error(t, err)
+}
+
+func TestClient_ListFiles(t *testing.T) {
+	client, server := newTestClient(t)
+	server.setFile("/a.gcode", []byte("a"))
+	server.setFile("/b.3mf", []byte("bbb"))
+	server.setFile("/timelapse/video.mp4", []byte("v"))
+
+	files, err := client.ListFiles("/")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "a.gcode", files[0].Name)
+	assert.Equal(t, "b.3mf", files[1].Name)
+	assert.Equal(t, int64(3), files[1].Size)
+	assert.False(t, files[1].IsDir)
+}
+
+func TestClient_DeleteAndRename(t *testing.T) {
+	client, server := newTestClient(t)
+	server.setFile("/a.gcode", []byte("a"))
+	server.setFile("/b.gcode", []byte("b"))
+
+	require.NoError(t, client.Rename("/a.gcode", "/c.gcode"))
+	require.NoError(t, client.Delete("/b.gcode"))
+	assert.Error(t, client.Delete("/b.gcode"))
+
+	_, ok := server.file("/a.gcode")
+	assert.False(t, ok)
+	_, ok = server.file("/b.gcode")
+	assert.False(t, ok)
+	content, ok := server.file("/c.gcode")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), content)
+}
+
+func TestClient_FreeSpace(t *testing.T) {
+	client, server := newTestClient(t)
+
+	_, err := client.FreeSpace("/")
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	server.free = 31 << 30
+	free, err := client.FreeSpace("/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(31<<30), free)
+}
+
+func TestClient_WrongAccessCode(t *testing.T) {
+	server, tlsConfig := newTestServer(t, "12345678")
+	config := server.config(tlsConfig)
+	config.AccessCode = "wrong"
+
+	client, err := NewClient(config)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.ListFiles("/")
+	assert.Error(t, err)
+}
@@ -0,0 +1,292 @@
+package ftp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testServer is a minimal implicit FTPS server behaving like the one of the
+// printers: LIST only, AVBL for the free space, and data connections that must
+// resume the TLS session of the control connection.
+type testServer struct {
+	t         *testing.T
+	listener  net.Listener
+	tlsConfig *tls.Config
+	password  string
+	free      int64
+
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newTestServer(t *testing.T, password string) (*testServer, *tls.Config) {
+	t.Helper()
+	cert, roots := newTestCertificate(t)
+
+	s := &testServer{
+		t:         t,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		password:  password,
+		free:      -1,
+		files:     make(map[string][]byte),
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig)
+	require.NoError(t, err)
+	s.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go s.serve()
+	return s, &tls.Config{RootCAs: roots}
+}
+
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "01P00A000000000"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}
+
+func (s *testServer) config(tlsConfig *tls.Config) *ClientConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return &ClientConfig{
+		Host:       addr.IP.String(),
+		Port:       addr.Port,
+		AccessCode: s.password,
+		Timeout:    5 * time.Second,
+		TLSConfig:  tlsConfig,
+	}
+}
+
+func (s *testServer) file(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[path.Clean(name)]
+	return data, ok
+}
+
+func (s *testServer) setFile(name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path.Clean(name)] = data
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(code int, msg string) {
+		fmt.Fprintf(conn, "%d %s\r\n", code, msg)
+	}
+
+	var (
+		data       net.Listener
+		renameFrom string
+		loggedIn   bool
+	)
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+
+	reply(220, "Service ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+
+		if !loggedIn && command != "USER" && command != "PASS" {
+			reply(530, "Not logged in")
+			continue
+		}
+
+		switch strings.ToUpper(command) {
+		case "USER":
+			reply(331, "Password required")
+		case "PASS":
+			if arg != s.password {
+				reply(530, "Login incorrect")
+				continue
+			}
+			loggedIn = true
+			reply(230, "Logged in")
+		case "FEAT":
+			fmt.Fprintf(conn, "211-Features:\r\n SIZE\r\n211 End\r\n")
+		case "PBSZ", "PROT", "TYPE":
+			reply(200, "OK")
+		case "EPSV":
+			if data != nil {
+				data.Close()
+			}
+			data, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply(425, "Cannot open data connection")
+				continue
+			}
+			reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port))
+		case "LIST":
+			s.transfer(conn, data, func(dc net.Conn) bool {
+				fmt.Fprint(dc, s.listing(arg))
+				return true
+			})
+		case "STOR":
+			s.transfer(conn, data, func(dc net.Conn) bool {
+				content, err := io.ReadAll(dc)
+				if err != nil {
+					return false
+				}
+				s.setFile(arg, content)
+				return true
+			})
+		case "RETR":
+			content, ok := s.file(arg)
+			if !ok {
+				reply(550, "No such file")
+				continue
+			}
+			s.transfer(conn, data, func(dc net.Conn) bool {
+				_, err := io.Copy(dc, bytes.NewReader(content))
+				return err == nil
+			})
+		case "SIZE":
+			content, ok := s.file(arg)
+			if !ok {
+				reply(550, "No such file")
+				continue
+			}
+			reply(213, fmt.Sprint(len(content)))
+		case "DELE":
+			s.mu.Lock()
+			_, ok := s.files[path.Clean(arg)]
+			delete(s.files, path.Clean(arg))
+			s.mu.Unlock()
+			if !ok {
+				reply(550, "No such file")
+				continue
+			}
+			reply(250, "Deleted")
+		case "RNFR":
+			if _, ok := s.file(arg); !ok {
+				reply(550, "No such file")
+				continue
+			}
+			renameFrom = arg
+			reply(350, "Ready for RNTO")
+		case "RNTO":
+			s.mu.Lock()
+			s.files[path.Clean(arg)] = s.files[path.Clean(renameFrom)]
+			delete(s.files, path.Clean(renameFrom))
+			s.mu.Unlock()
+			reply(250, "Renamed")
+		case "AVBL":
+			if s.free < 0 {
+				reply(502, "Command not implemented")
+				continue
+			}
+			reply(213, fmt.Sprint(s.free))
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			reply(502, "Command not implemented")
+		}
+	}
+}
+
+// transfer accepts the data connection opened after EPSV, rejecting it unless it
+// resumes the TLS session of the control connection, and runs fn over it.
+func (s *testServer) transfer(conn net.Conn, data net.Listener, fn func(net.Conn) bool) {
+	if data == nil {
+		fmt.Fprintf(conn, "425 Use EPSV first\r\n")
+		return
+	}
+	fmt.Fprintf(conn, "150 Opening data connection\r\n")
+
+	raw, err := data.Accept()
+	if err != nil {
+		fmt.Fprintf(conn, "425 Cannot open data connection\r\n")
+		return
+	}
+	dc := tls.Server(raw, s.tlsConfig)
+	defer dc.Close()
+
+	if err := dc.Handshake(); err != nil || !dc.ConnectionState().DidResume {
+		fmt.Fprintf(conn, "522 SSL connection failed: session reuse required\r\n")
+		return
+	}
+
+	if !fn(dc) {
+		fmt.Fprintf(conn, "426 Transfer aborted\r\n")
+		return
+	}
+	dc.Close()
+	fmt.Fprintf(conn, "226 Transfer complete\r\n")
+}
+
+func (s *testServer) listing(dir string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir = path.Clean("/" + dir)
+	var names []string
+	for name := range s.files {
+		if path.Dir(name) == dir {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "-rw-rw-rw- 1 root root %d Jan 02 15:04 %s\r\n", len(s.files[name]), path.Base(name))
+	}
+	return b.String()
+}
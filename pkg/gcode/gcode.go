@@ -0,0 +1,268 @@
+package gcode
+
+import (
+	"bufio"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// G-code exported by Bambu Studio and OrcaSlicer starts with a header of comments
+// describing the print, followed by base64 thumbnails and, for Bambu Studio, the
+// slicer settings. OrcaSlicer writes the settings at the end of the file instead:
+//
+//	; HEADER_BLOCK_START
+//	; BambuStudio 01.09.07.52
+//	; model printing time: 1h 2m 5s; total estimated time: 1h 8m 30s
+//	; total layer number: 150
+//	; total filament length [mm] : 5123.45,3020.11
+//	; total filament weight [g] : 15.27,9.29
+//	; HEADER_BLOCK_END
+//
+// Layers start with a "; CHANGE_LAYER" (Bambu Studio) or ";LAYER_CHANGE"
+// (OrcaSlicer) comment, and M73 commands report the remaining time as the print
+// progresses.
+
+const maxLineSize = 16 << 20
+
+type Metadata struct {
+	Slicer            string        `json:"slicer"`              // Slicer and version that generated the file
+	PrinterModel      string        `json:"printer_model"`       // Printer the file was sliced for ("Bambu Lab P1S")
+	EstimatedTime     time.Duration `json:"estimated_time"`      // Estimated time of the whole print
+	ModelPrintingTime time.Duration `json:"model_printing_time"` // Estimated time spent printing the model, without preparation
+	LayerCount        int           `json:"layer_count"`         // Number of layers announced in the header
+	MaxZ              float64       `json:"max_z"`               // Height of the print (mm)
+	NozzleDiameter    float64       `json:"nozzle_diameter"`     // Nozzle diameter the file was sliced for (mm)
+	FilamentLength    []float64     `json:"filament_length"`     // Length used of each filament (mm)
+	FilamentWeight    []float64     `json:"filament_weight"`     // Weight used of each filament (grams)
+	FilamentTypes     []string      `json:"filament_types"`      // Type of each filament
+	FilamentColors    []color.RGBA  `json:"filament_colors"`     // Colour of each filament
+}
+
+// Layer is a layer of the print.
+type Layer struct {
+	Z         float64       `json:"z"`         // Height of the layer (mm)
+	Remaining time.Duration `json:"remaining"` // Time left when the layer starts, -1 if unknown
+}
+
+// Analysis is the result of reading a G-code file.
+type Analysis struct {
+	Metadata
+	Thumbnails []Thumbnail `json:"thumbnails"` // Thumbnails embedded in the file, in order
+	Layers     []Layer     `json:"layers"`     // Layers found in the file, in order
+}
+
+// AnalyzeFile analyzes the G-code file at path.
+func AnalyzeFile(path string) (*Analysis, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Analyze(f)
+}
+
+// Analyze reads a G-code file to the end, collecting its metadata, thumbnails and
+// layers. The file is read line by line and never held in memory.
+func Analyze(r io.Reader) (*Analysis, error) {
+	analysis := &Analysis{}
+	thumbnails := &thumbnailReader{}
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, ";") {
+			analysis.command(line)
+			continue
+		}
+
+		comment := strings.TrimSpace(strings.TrimPrefix(line, ";"))
+		if thumbnails.comment(comment) {
+			continue
+		}
+		analysis.comment(comment)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	analysis.Thumbnails = thumbnails.decode()
+	return analysis, nil
+}
+
+// Progress predicts the progress of the print while the given layer is printed,
+// numbered from 1 like the layer reported by the printer: the percentage done and
+// the time left. The remaining time comes from the M73 commands of the file; without
+// them, progress is estimated from the number of layers.
+func (a *Analysis) Progress(layer int) (percent float64, remaining time.Duration) {
+	switch {
+	case layer <= 0:
+		return 0, a.EstimatedTime
+	case layer > len(a.Layers):
+		return 100, 0
+	}
+
+	remaining = a.Layers[layer-1].Remaining
+	if remaining >= 0 && a.EstimatedTime > 0 {
+		return 100 * (1 - min(remaining.Seconds()/a.EstimatedTime.Seconds(), 1)), remaining
+	}
+
+	percent = 100 * float64(layer-1) / float64(len(a.Layers))
+	if remaining < 0 {
+		remaining = time.Duration(float64(a.EstimatedTime) * (1 - percent/100))
+	}
+	return percent, remaining
+}
+
+func (a *Analysis) comment(comment string) {
+	switch {
+	case comment == "CHANGE_LAYER" || comment == "LAYER_CHANGE":
+		a.Layers = append(a.Layers, Layer{Remaining: -1})
+		return
+	case strings.HasPrefix(comment, "BambuStudio "), strings.HasPrefix(comment, "OrcaSlicer "):
+		if a.Slicer == "" {
+			a.Slicer = comment
+		}
+		return
+	case strings.HasPrefix(comment, "generated by "):
+		if a.Slicer == "" {
+			slicer, _, _ := strings.Cut(strings.TrimPrefix(comment, "generated by "), " on ")
+			a.Slicer = slicer
+		}
+		return
+	}
+
+	// The header puts both times on one line.
+	if strings.HasPrefix(comment, "model printing time") {
+		for _, part := range strings.Split(comment, "; ") {
+			a.setting(part)
+		}
+		return
+	}
+	a.setting(comment)
+}
+
+// setting records a "key: value" or "key = value" comment if it is one of the
+// settings of interest.
+func (a *Analysis) setting(comment string) {
+	i := strings.IndexAny(comment, ":=")
+	if i < 0 {
+		return
+	}
+	key := strings.ToLower(strings.TrimSpace(comment[:i]))
+	value := strings.TrimSpace(comment[i+1:])
+
+	switch key {
+	case "z_height", "z":
+		if len(a.Layers) > 0 {
+			a.Layers[len(a.Layers)-1].Z = parseFloat(value)
+		}
+	case "total estimated time", "estimated printing time (normal mode)":
+		a.EstimatedTime = parseDuration(value)
+	case "model printing time":
+		a.ModelPrintingTime = parseDuration(value)
+	case "total layer number", "total layers count":
+		a.LayerCount, _ = strconv.Atoi(value)
+	case "max_z_height":
+		a.MaxZ = parseFloat(value)
+	case "total filament length [mm]", "filament used [mm]":
+		a.FilamentLength = parseFloats(value)
+	case "total filament weight [g]", "filament used [g]":
+		a.FilamentWeight = parseFloats(value)
+	case "printer_model":
+		a.PrinterModel = value
+	case "nozzle_diameter":
+		if diameters := parseFloats(value); len(diameters) > 0 {
+			a.NozzleDiameter = diameters[0]
+		}
+	case "filament_type":
+		a.FilamentTypes = strings.Split(value, ";")
+	case "filament_colour":
+		a.FilamentColors = a.FilamentColors[:0]
+		for _, c := range strings.Split(value, ";") {
+			a.FilamentColors = append(a.FilamentColors, parseColor(c))
+		}
+	}
+}
+
+// command records the remaining time reported by M73 commands on the current layer.
+func (a *Analysis) command(line string) {
+	line, _, _ = strings.Cut(line, ";")
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "M73" || len(a.Layers) == 0 {
+		return
+	}
+
+	layer := &a.Layers[len(a.Layers)-1]
+	if layer.Remaining >= 0 {
+		return
+	}
+	for _, field := range fields[1:] {
+		if minutes, ok := strings.CutPrefix(field, "R"); ok {
+			if v, err := strconv.Atoi(minutes); err == nil {
+				layer.Remaining = time.Duration(v) * time.Minute
+			}
+		}
+	}
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return scanner
+}
+
+// parseDuration parses durations written by slicers, such as "1d 2h 3m 4s".
+func parseDuration(s string) time.Duration {
+	var d time.Duration
+	for _, field := range strings.Fields(s) {
+		if len(field) < 2 {
+			continue
+		}
+		v, err := strconv.Atoi(field[:len(field)-1])
+		if err != nil {
+			continue
+		}
+		switch field[len(field)-1] {
+		case 'd':
+			d += time.Duration(v) * 24 * time.Hour
+		case 'h':
+			d += time.Duration(v) * time.Hour
+		case 'm':
+			d += time.Duration(v) * time.Minute
+		case 's':
+			d += time.Duration(v) * time.Second
+		}
+	}
+	return d
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f
+}
+
+func parseFloats(s string) []float64 {
+	var values []float64
+	for _, v := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		values = append(values, parseFloat(v))
+	}
+	return values
+}
+
+// parseColor parses a #RRGGBB colour, returning transparent black if it is invalid.
+func parseColor(s string) color.RGBA {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
@@ -0,0 +1,169 @@
+package gcode
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/gcode/gcodetest"
+)
+
+const bambuHeader = `; HEADER_BLOCK_START
+; BambuStudio 01.09.07.52
+; model printing time: 1h 2m 5s; total estimated time: 1h 8m 30s
+; total layer number: 3
+; total filament length [mm] : 5123.45,3020.11
+; total filament volume [cm^3] : 12.32,7.26
+; total filament weight [g] : 15.27,9.29
+; model label id: 143,211
+; max_z_height: 0.60
+; HEADER_BLOCK_END
+
+; CONFIG_BLOCK_START
+; filament_colour = #FFFFFF;#0A2989
+; filament_type = PLA;PETG
+; nozzle_diameter = 0.4
+; printer_model = Bambu Lab P1S
+; CONFIG_BLOCK_END
+`
+
+const bambuBody = `M73 P0 R68
+G28
+; CHANGE_LAYER
+; Z_HEIGHT: 0.2
+; LAYER_HEIGHT: 0.2
+M73 P10 R60
+G1 X10 Y10 E1 ; first layer
+M73 P15 R55
+; CHANGE_LAYER
+; Z_HEIGHT: 0.4
+M73 L2
+M73 P50 R30
+G1 X20 Y20 E1
+; CHANGE_LAYER
+; Z_HEIGHT: 0.6
+M73 P80 R12
+G1 X30 Y30 E1
+M73 P100 R0
+`
+
+func TestAnalyze(t *testing.T) {
+	analysis, err := Analyze(strings.NewReader(bambuHeader + gcodetest.Thumbnails(32, 128) + bambuBody))
+	require.NoError(t, err)
+
+	assert.Equal(t, Metadata{
+		Slicer:            "BambuStudio 01.09.07.52",
+		PrinterModel:      "Bambu Lab P1S",
+		EstimatedTime:     time.Hour + 8*time.Minute + 30*time.Second,
+		ModelPrintingTime: time.Hour + 2*time.Minute + 5*time.Second,
+		LayerCount:        3,
+		MaxZ:              0.6,
+		NozzleDiameter:    0.4,
+		FilamentLength:    []float64{5123.45, 3020.11},
+		FilamentWeight:    []float64{15.27, 9.29},
+		FilamentTypes:     []string{"PLA", "PETG"},
+		FilamentColors:    []color.RGBA{{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, {R: 0x0a, G: 0x29, B: 0x89, A: 0xff}},
+	}, analysis.Metadata)
+
+	assert.Equal(t, []Layer{
+		{Z: 0.2, Remaining: 60 * time.Minute},
+		{Z: 0.4, Remaining: 30 * time.Minute},
+		{Z: 0.6, Remaining: 12 * time.Minute},
+	}, analysis.Layers)
+
+	require.Len(t, analysis.Thumbnails, 2)
+	assert.Equal(t, 32, analysis.Thumbnails[0].Width)
+	assert.Equal(t, 128, analysis.Thumbnails[1].Height)
+}
+
+func TestAnalyze_OrcaSlicer(t *testing.T) {
+	gcode := `; generated by OrcaSlicer 2.1.1 on 2024-08-01 at 10:12:34
+; total layers count = 2
+G28
+;LAYER_CHANGE
+;Z:0.2
+;HEIGHT:0.2
+G1 X10 Y10 E1
+;LAYER_CHANGE
+;Z:0.4
+G1 X20 Y20 E1
+
+; filament used [mm] = 812.5
+; filament used [g] = 2.42
+; estimated printing time (normal mode) = 25m 12s
+
+; CONFIG_BLOCK_START
+; printer_model = Bambu Lab A1 mini
+; nozzle_diameter = 0.4,0.4
+; CONFIG_BLOCK_END
+`
+	analysis, err := Analyze(strings.NewReader(gcode))
+	require.NoError(t, err)
+
+	assert.Equal(t, "OrcaSlicer 2.1.1", analysis.Slicer)
+	assert.Equal(t, "Bambu Lab A1 mini", analysis.PrinterModel)
+	assert.Equal(t, 25*time.Minute+12*time.Second, analysis.EstimatedTime)
+	assert.Equal(t, 2, analysis.LayerCount)
+	assert.Equal(t, 0.4, analysis.NozzleDiameter)
+	assert.Equal(t, []float64{812.5}, analysis.FilamentLength)
+	assert.Equal(t, []float64{2.42}, analysis.FilamentWeight)
+	assert.Equal(t, []Layer{{Z: 0.2, Remaining: -1}, {Z: 0.4, Remaining: -1}}, analysis.Layers)
+	assert.Empty(t, analysis.Thumbnails)
+}
+
+func TestAnalyze_LongLines(t *testing.T) {
+	gcode := "; machine_start_gcode = " + strings.Repeat("G1 X0\\n", 100_000) + "\n; printer_model = Bambu Lab X1\n"
+
+	analysis, err := Analyze(strings.NewReader(gcode))
+	require.NoError(t, err)
+	assert.Equal(t, "Bambu Lab X1", analysis.PrinterModel)
+}
+
+func TestAnalyzeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cube.gcode")
+	require.NoError(t, os.WriteFile(path, []byte(bambuHeader+bambuBody), 0o644))
+
+	analysis, err := AnalyzeFile(path)
+	require.NoError(t, err)
+	assert.Len(t, analysis.Layers, 3)
+
+	_, err = AnalyzeFile(filepath.Join(t.TempDir(), "missing.gcode"))
+	assert.Error(t, err)
+}
+
+func TestAnalysis_Progress(t *testing.T) {
+	analysis, err := Analyze(strings.NewReader(bambuHeader + bambuBody))
+	require.NoError(t, err)
+
+	percent, remaining := analysis.Progress(0)
+	assert.Zero(t, percent)
+	assert.Equal(t, analysis.EstimatedTime, remaining)
+
+	percent, remaining = analysis.Progress(2)
+	assert.Equal(t, 30*time.Minute, remaining)
+	assert.InDelta(t, 56.2, percent, 0.1)
+
+	percent, remaining = analysis.Progress(4)
+	assert.Equal(t, 100.0, percent)
+	assert.Zero(t, remaining)
+
+	// Without M73 commands, progress follows the layers.
+	analysis = &Analysis{
+		Metadata: Metadata{EstimatedTime: time.Hour},
+		Layers:   []Layer{{Remaining: -1}, {Remaining: -1}, {Remaining: -1}, {Remaining: -1}},
+	}
+	percent, remaining = analysis.Progress(3)
+	assert.Equal(t, 50.0, percent)
+	assert.Equal(t, 30*time.Minute, remaining)
+}
+
+func TestParseDuration(t *testing.T) {
+	assert.Equal(t, 26*time.Hour+3*time.Minute+4*time.Second, parseDuration("1d 2h 3m 4s"))
+	assert.Equal(t, 45*time.Second, parseDuration("45s"))
+	assert.Zero(t, parseDuration("unknown"))
+}
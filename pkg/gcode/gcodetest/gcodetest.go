@@ -0,0 +1,45 @@
+// Package gcodetest builds G-code for tests of the packages reading it.
+package gcodetest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+)
+
+// Thumbnails returns the thumbnail block of a G-code file with a PNG thumbnail of
+// each given size, wrapped over several comment lines like slicers do.
+func Thumbnails(sizes ...int) string {
+	var gcode strings.Builder
+	gcode.WriteString("; THUMBNAIL_BLOCK_START\n")
+	for _, size := range sizes {
+		WriteThumbnail(&gcode, "thumbnail", size, PNG(size))
+	}
+	gcode.WriteString("; THUMBNAIL_BLOCK_END\n\n")
+	return gcode.String()
+}
+
+// WriteThumbnail writes an encoded image as a size×size thumbnail between the
+// "begin" and "end" comments of keyword, such as "thumbnail" or "thumbnail_JPG".
+func WriteThumbnail(gcode *strings.Builder, keyword string, size int, image []byte) {
+	data := base64.StdEncoding.EncodeToString(image)
+	fmt.Fprintf(gcode, ";\n; %s begin %dx%d %d\n", keyword, size, size, len(data))
+	for len(data) > 0 {
+		n := min(len(data), 78)
+		fmt.Fprintf(gcode, "; %s\n", data[:n])
+		data = data[n:]
+	}
+	fmt.Fprintf(gcode, "; %s end\n", keyword)
+}
+
+// PNG returns a blank size×size PNG image.
+func PNG(size int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, size, size))); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,116 @@
+package gcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strings"
+)
+
+// Thumbnails are embedded as base64 data wrapped over comment lines:
+//
+//	; thumbnail begin 300x300 12345
+//	; iVBORw0KGgoAAAANSUhEUgAA...
+//	; thumbnail end
+//
+// OrcaSlicer can also write JPEG and QOI thumbnails, between "thumbnail_JPG begin"
+// and "thumbnail_JPG end" and likewise. Thumbnails in a format the image package
+// cannot decode, such as QOI, are skipped.
+
+// ErrNoThumbnail is returned when a file has no thumbnail.
+var ErrNoThumbnail = errors.New("no thumbnail")
+
+type Thumbnail struct {
+	Width  int         `json:"width"`
+	Height int         `json:"height"`
+	Image  image.Image `json:"-"`
+}
+
+// ReadThumbnails reads the thumbnails embedded in the comments at the start of a
+// G-code file. It stops at the first command, so that only the header is read.
+func ReadThumbnails(r io.Reader) ([]Thumbnail, error) {
+	thumbnails := &thumbnailReader{}
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, ";") {
+			break
+		}
+		thumbnails.comment(strings.TrimSpace(strings.TrimPrefix(line, ";")))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return thumbnails.decode(), nil
+}
+
+// Largest returns the image of the largest thumbnail.
+func Largest(thumbnails []Thumbnail) (image.Image, error) {
+	if len(thumbnails) == 0 {
+		return nil, ErrNoThumbnail
+	}
+
+	largest := thumbnails[0]
+	for _, thumbnail := range thumbnails[1:] {
+		if thumbnail.Width*thumbnail.Height > largest.Width*largest.Height {
+			largest = thumbnail
+		}
+	}
+	return largest.Image, nil
+}
+
+// thumbnailReader collects the base64 data of the thumbnails from comment lines.
+type thumbnailReader struct {
+	encoded [][]byte
+	current *bytes.Buffer
+}
+
+// comment handles a comment line, reporting whether it belongs to a thumbnail.
+func (t *thumbnailReader) comment(comment string) bool {
+	keyword, rest, _ := strings.Cut(comment, " ")
+	if !strings.HasPrefix(keyword, "thumbnail") {
+		if t.current != nil {
+			t.current.WriteString(comment)
+			return true
+		}
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(rest, "begin"):
+		t.current = &bytes.Buffer{}
+	case rest == "end" && t.current != nil:
+		t.encoded = append(t.encoded, t.current.Bytes())
+		t.current = nil
+	default:
+		return t.current != nil
+	}
+	return true
+}
+
+// decode decodes the collected thumbnails, skipping those that cannot be decoded so
+// that they do not hide the others.
+func (t *thumbnailReader) decode() []Thumbnail {
+	thumbnails := make([]Thumbnail, 0, len(t.encoded))
+	for _, encoded := range t.encoded {
+		data, err := base64.StdEncoding.DecodeString(string(encoded))
+		if err != nil {
+			continue
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		bounds := img.Bounds()
+		thumbnails = append(thumbnails, Thumbnail{Width: bounds.Dx(), Height: bounds.Dy(), Image: img})
+	}
+	return thumbnails
+}
@@ -0,0 +1,58 @@
+package gcode
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/gcode/gcodetest"
+)
+
+func TestReadThumbnails(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 48, 48)), nil))
+	var jpg strings.Builder
+	gcodetest.WriteThumbnail(&jpg, "thumbnail_JPG", 48, buf.Bytes())
+
+	// Thumbnails after the first command are not read.
+	gcode := "; HEADER_BLOCK_START\n; HEADER_BLOCK_END\n" + gcodetest.Thumbnails(16, 64) + jpg.String() + "G28\n" + gcodetest.Thumbnails(256)
+
+	thumbnails, err := ReadThumbnails(strings.NewReader(gcode))
+	require.NoError(t, err)
+	require.Len(t, thumbnails, 3)
+	assert.Equal(t, 16, thumbnails[0].Width)
+	assert.Equal(t, 48, thumbnails[2].Width)
+
+	largest, err := Largest(thumbnails)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 64, 64), largest.Bounds())
+}
+
+func TestReadThumbnails_Invalid(t *testing.T) {
+	// Thumbnails that cannot be decoded, such as QOI ones, are skipped and the
+	// others still returned.
+	var gcode strings.Builder
+	gcode.WriteString("; thumbnail begin 1x1 4\n; !!!!\n; thumbnail end\n")
+	gcode.WriteString("; thumbnail begin 1x1 4\n; aGVsbG8=\n; thumbnail end\n")
+	gcodetest.WriteThumbnail(&gcode, "thumbnail_QOI", 16, []byte("qoif\x00\x00\x00\x10\x00\x00\x00\x10\x04\x00"))
+	gcode.WriteString(gcodetest.Thumbnails(32))
+
+	thumbnails, err := ReadThumbnails(strings.NewReader(gcode.String()))
+	require.NoError(t, err)
+	require.Len(t, thumbnails, 1)
+	assert.Equal(t, 32, thumbnails[0].Width)
+
+	thumbnails, err = ReadThumbnails(strings.NewReader("; thumbnail begin 1x1 4\n; aGVsbG8=\n; thumbnail end\nG28\n"))
+	require.NoError(t, err)
+	_, err = Largest(thumbnails)
+	assert.ErrorIs(t, err, ErrNoThumbnail)
+
+	thumbnails, err = ReadThumbnails(strings.NewReader("G28\n"))
+	require.NoError(t, err)
+	_, err = Largest(thumbnails)
+	assert.ErrorIs(t, err, ErrNoThumbnail)
+}
@@ -0,0 +1,367 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// The benchmarks below compare the ingestion path against the previous
+// implementation, which decoded every report into a full Message, merged it
+// field by field with reflection and compiled the topic regex for every message.
+// legacyMessage is the Message type as it was at the time.
+
+// A printer sends a full report after a push_all and small deltas in between.
+var benchmarkReports = []struct {
+	name    string
+	payload []byte
+}{
+	{"full", []byte(fullReport)},
+	{"delta", []byte(`{"print":{"command":"push_status","sequence_id":"3","nozzle_temper":219.5,"bed_temper":60.1,"mc_percent":43,"wifi_signal":"-51dBm"}}`)},
+	{"ams", []byte(`{"print":{"command":"push_status","sequence_id":"5","ams":{"tray_now":"2","ams":[{"id":"0","humidity":"4","temp":"24.6","tray":[{"id":"0","remain":79},{"id":"1","remain":50},{"id":"2","remain":10},{"id":"3","remain":-1}]}]}}}`)},
+}
+
+type legacyClient struct {
+	mutex sync.Mutex
+	data  map[string]legacyMessage
+}
+
+func (c *legacyClient) ingest(topic string, payload []byte) {
+	var received legacyMessage
+	if err := json.Unmarshal(payload, &received); err != nil {
+		return
+	}
+
+	serial := legacyExtractSerialFromTopic(topic)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if existing, exists := c.data[serial]; exists {
+		legacyMergeStructs(&existing.Print, &received.Print)
+		c.data[serial] = existing
+	} else {
+		c.data[serial] = received
+	}
+}
+
+func legacyExtractSerialFromTopic(topic string) string {
+	re := regexp.MustCompile(`device/([^/]+)/report`)
+	matches := re.FindStringSubmatch(topic)
+	if len(matches) > 1 {
+		return matches[1]
+	}
+	return ""
+}
+
+func legacyMergeStructs(existing, new interface{}) {
+	existingVal := reflect.ValueOf(existing).Elem()
+	newVal := reflect.ValueOf(new).Elem()
+
+	for i := 0; i < existingVal.NumField(); i++ {
+		field := existingVal.Field(i)
+		newField := newVal.Field(i)
+		if !newField.IsZero() {
+			if newField.Kind() == reflect.Struct {
+				legacyMergeStructs(field.Addr().Interface(), newField.Addr().Interface())
+			} else {
+				field.Set(newField)
+			}
+		}
+	}
+}
+
+func benchmarkTopics(printers int) []string {
+	topics := make([]string, printers)
+	for i := range topics {
+		topics[i] = fmt.Sprintf(topicTemplate, fmt.Sprintf("01P00A%09d", i))
+	}
+	return topics
+}
+
+func BenchmarkIngest(b *testing.B) {
+	topics := benchmarkTopics(60)
+
+	for _, report := range benchmarkReports {
+		b.Run(report.name+"/legacy", func(b *testing.B) {
+			client := &legacyClient{data: make(map[string]legacyMessage)}
+			for _, topic := range topics {
+				client.ingest(topic, []byte(fullReport))
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				client.ingest(topics[i%len(topics)], report.payload)
+			}
+		})
+
+		b.Run(report.name+"/current", func(b *testing.B) {
+			client := newTestClient()
+			for _, topic := range topics {
+				client.ingest(extractSerialFromTopic(topic), []byte(fullReport))
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				client.ingest(extractSerialFromTopic(topics[i%len(topics)]), report.payload)
+			}
+		})
+	}
+}
+
+// benchmarkVariants returns copies of a report with a different sequence_id and
+// first tray remain, so that the raw document cannot skip them as repeated.
+func benchmarkVariants(payload []byte, count int) [][]byte {
+	sequenceID := regexp.MustCompile(`"sequence_id":"\d+"`)
+	remain := regexp.MustCompile(`"remain":-?\d+`)
+
+	variants := make([][]byte, count)
+	for i := range variants {
+		variant := sequenceID.ReplaceAll(payload, []byte(`"sequence_id":"`+strconv.Itoa(i)+`"`))
+		if loc := remain.FindIndex(variant); loc != nil {
+			variant = append(variant[:loc[0]:loc[0]], append([]byte(`"remain":`+strconv.Itoa(i%100)), variant[loc[1]:]...)...)
+		}
+		variants[i] = variant
+	}
+	return variants
+}
+
+// BenchmarkRawData reads the raw document after every report, the worst case for
+// the raw path since every report is then merged on its own.
+func BenchmarkRawData(b *testing.B) {
+	for _, report := range benchmarkReports {
+		b.Run(report.name, func(b *testing.B) {
+			client := newTestClient()
+			client.ingest("SERIAL", []byte(fullReport))
+			client.RawData("SERIAL")
+			variants := benchmarkVariants(report.payload, 64)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				client.ingest("SERIAL", variants[i%len(variants)])
+				client.RawData("SERIAL")
+			}
+		})
+	}
+}
+
+func BenchmarkIngestParallel(b *testing.B) {
+	topics := benchmarkTopics(60)
+	delta := benchmarkReports[1].payload
+
+	b.Run("legacy", func(b *testing.B) {
+		client := &legacyClient{data: make(map[string]legacyMessage)}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				client.ingest(topics[i%len(topics)], delta)
+				i++
+			}
+		})
+	})
+
+	b.Run("current", func(b *testing.B) {
+		client := newTestClient()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				client.ingest(extractSerialFromTopic(topics[i%len(topics)]), delta)
+				i++
+			}
+		})
+	})
+}
+
+func BenchmarkExtractSerialFromTopic(b *testing.B) {
+	topic := fmt.Sprintf(topicTemplate, "01P00A000000000")
+
+	b.Run("legacy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			legacyExtractSerialFromTopic(topic)
+		}
+	})
+
+	b.Run("current", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			extractSerialFromTopic(topic)
+		}
+	})
+}
+
+type legacyMessage struct {
+	Print struct {
+		Ams struct {
+			Ams []struct {
+				Humidity string `json:"humidity"`
+				ID       string `json:"id"`
+				Temp     string `json:"temp"`
+				Tray     []struct {
+					ID            string   `json:"id"`
+					BedTemp       string   `json:"bed_temp,omitempty"`
+					BedTempType   string   `json:"bed_temp_type,omitempty"`
+					Cols          []string `json:"cols,omitempty"`
+					DryingTemp    string   `json:"drying_temp,omitempty"`
+					DryingTime    string   `json:"drying_time,omitempty"`
+					NozzleTempMax string   `json:"nozzle_temp_max,omitempty"`
+					NozzleTempMin string   `json:"nozzle_temp_min,omitempty"`
+					Remain        int      `json:"remain,omitempty"`
+					TagUID        string   `json:"tag_uid,omitempty"`
+					TrayColor     string   `json:"tray_color,omitempty"`
+					TrayDiameter  string   `json:"tray_diameter,omitempty"`
+					TrayIDName    string   `json:"tray_id_name,omitempty"`
+					TrayInfoIdx   string   `json:"tray_info_idx,omitempty"`
+					TraySubBrands string   `json:"tray_sub_brands,omitempty"`
+					TrayType      string   `json:"tray_type,omitempty"`
+					TrayUUID      string   `json:"tray_uuid,omitempty"`
+					TrayWeight    string   `json:"tray_weight,omitempty"`
+					XcamInfo      string   `json:"xcam_info,omitempty"`
+				} `json:"tray"`
+			} `json:"ams"`
+			AmsExistBits     string `json:"ams_exist_bits"`
+			InsertFlag       bool   `json:"insert_flag"`
+			PowerOnFlag      bool   `json:"power_on_flag"`
+			TrayExistBits    string `json:"tray_exist_bits"`
+			TrayIsBblBits    string `json:"tray_is_bbl_bits"`
+			TrayNow          string `json:"tray_now"`
+			TrayReadDoneBits string `json:"tray_read_done_bits"`
+			TrayReadingBits  string `json:"tray_reading_bits"`
+			TrayTar          string `json:"tray_tar"`
+			Version          int    `json:"version"`
+		} `json:"ams"`
+		AmsRfidStatus           int     `json:"ams_rfid_status"`
+		AmsStatus               int     `json:"ams_status"`
+		AuxPartFan              bool    `json:"aux_part_fan"`
+		BedTargetTemper         float64 `json:"bed_target_temper"`
+		BedTemper               float64 `json:"bed_temper"`
+		BigFan1Speed            string  `json:"big_fan1_speed"`
+		BigFan2Speed            string  `json:"big_fan2_speed"`
+		ChamberTemper           float64 `json:"chamber_temper"`
+		Command                 string  `json:"command"`
+		CoolingFanSpeed         string  `json:"cooling_fan_speed"`
+		FailReason              string  `json:"fail_reason"`
+		FanGear                 int     `json:"fan_gear"`
+		FilamBak                []any   `json:"filam_bak"`
+		ForceUpgrade            bool    `json:"force_upgrade"`
+		GcodeFile               string  `json:"gcode_file"`
+		GcodeFilePreparePercent string  `json:"gcode_file_prepare_percent"`
+		GcodeStartTime          string  `json:"gcode_start_time"`
+		GcodeState              string  `json:"gcode_state"`
+		HeatbreakFanSpeed       string  `json:"heatbreak_fan_speed"`
+		Hms                     []any   `json:"hms"`
+		HomeFlag                int     `json:"home_flag"`
+		HwSwitchState           int     `json:"hw_switch_state"`
+		Ipcam                   struct {
+			IpcamDev    string `json:"ipcam_dev"`
+			IpcamRecord string `json:"ipcam_record"`
+			Resolution  string `json:"resolution"`
+			Timelapse   string `json:"timelapse"`
+		} `json:"ipcam"`
+		LayerNum     int    `json:"layer_num"`
+		Lifecycle    string `json:"lifecycle"`
+		LightsReport []struct {
+			Mode string `json:"mode"`
+			Node string `json:"node"`
+		} `json:"lights_report"`
+		Maintain            int     `json:"maintain"`
+		McPercent           int     `json:"mc_percent"`
+		McPrintErrorCode    string  `json:"mc_print_error_code"`
+		McPrintStage        string  `json:"mc_print_stage"`
+		McPrintSubStage     int     `json:"mc_print_sub_stage"`
+		McRemainingTime     int     `json:"mc_remaining_time"`
+		MessProductionState string  `json:"mess_production_state"`
+		NozzleDiameter      string  `json:"nozzle_diameter"`
+		NozzleTargetTemper  float64 `json:"nozzle_target_temper"`
+		NozzleTemper        float64 `json:"nozzle_temper"`
+		Online              struct {
+			Ahb     bool `json:"ahb"`
+			Rfid    bool `json:"rfid"`
+			Version int  `json:"version"`
+		} `json:"online"`
+		PrintError       int    `json:"print_error"`
+		PrintGcodeAction int    `json:"print_gcode_action"`
+		PrintRealAction  int    `json:"print_real_action"`
+		PrintType        string `json:"print_type"`
+		ProfileID        string `json:"profile_id"`
+		ProjectID        string `json:"project_id"`
+		QueueNumber      int    `json:"queue_number"`
+		Sdcard           bool   `json:"sdcard"`
+		SequenceID       string `json:"sequence_id"`
+		SpdLvl           int    `json:"spd_lvl"`
+		SpdMag           int    `json:"spd_mag"`
+		Stg              []any  `json:"stg"`
+		StgCur           int    `json:"stg_cur"`
+		SubtaskID        string `json:"subtask_id"`
+		SubtaskName      string `json:"subtask_name"`
+		TaskID           string `json:"task_id"`
+		TotalLayerNum    int    `json:"total_layer_num"`
+		UpgradeState     struct {
+			AhbNewVersionNumber string `json:"ahb_new_version_number"`
+			AmsNewVersionNumber string `json:"ams_new_version_number"`
+			ConsistencyRequest  bool   `json:"consistency_request"`
+			DisState            int    `json:"dis_state"`
+			ErrCode             int    `json:"err_code"`
+			ForceUpgrade        bool   `json:"force_upgrade"`
+			Message             string `json:"message"`
+			Module              string `json:"module"`
+			NewVersionState     int    `json:"new_version_state"`
+			OtaNewVersionNumber string `json:"ota_new_version_number"`
+			Progress            string `json:"progress"`
+			SequenceID          int    `json:"sequence_id"`
+			Status              string `json:"status"`
+		} `json:"upgrade_state"`
+		Upload struct {
+			FileSize      int    `json:"file_size"`
+			FinishSize    int    `json:"finish_size"`
+			Message       string `json:"message"`
+			OssURL        string `json:"oss_url"`
+			Progress      int    `json:"progress"`
+			SequenceID    string `json:"sequence_id"`
+			Speed         int    `json:"speed"`
+			Status        string `json:"status"`
+			TaskID        string `json:"task_id"`
+			TimeRemaining int    `json:"time_remaining"`
+			TroubleID     string `json:"trouble_id"`
+		} `json:"upload"`
+		VtTray struct {
+			BedTemp       string   `json:"bed_temp"`
+			BedTempType   string   `json:"bed_temp_type"`
+			Cols          []string `json:"cols"`
+			DryingTemp    string   `json:"drying_temp"`
+			DryingTime    string   `json:"drying_time"`
+			ID            string   `json:"id"`
+			NozzleTempMax string   `json:"nozzle_temp_max"`
+			NozzleTempMin string   `json:"nozzle_temp_min"`
+			Remain        int      `json:"remain"`
+			TagUID        string   `json:"tag_uid"`
+			TrayColor     string   `json:"tray_color"`
+			TrayDiameter  string   `json:"tray_diameter"`
+			TrayIDName    string   `json:"tray_id_name"`
+			TrayInfoIdx   string   `json:"tray_info_idx"`
+			TraySubBrands string   `json:"tray_sub_brands"`
+			TrayType      string   `json:"tray_type"`
+			TrayUUID      string   `json:"tray_uuid"`
+			TrayWeight    string   `json:"tray_weight"`
+			XcamInfo      string   `json:"xcam_info"`
+		} `json:"vt_tray"`
+		WifiSignal string `json:"wifi_signal"`
+		Xcam       struct {
+			AllowSkipParts           bool   `json:"allow_skip_parts"`
+			BuildplateMarkerDetector bool   `json:"buildplate_marker_detector"`
+			FirstLayerInspector      bool   `json:"first_layer_inspector"`
+			HaltPrintSensitivity     string `json:"halt_print_sensitivity"`
+			PrintHalt                bool   `json:"print_halt"`
+			PrintingMonitor          bool   `json:"printing_monitor"`
+			SpaghettiDetector        bool   `json:"spaghetti_detector"`
+		} `json:"xcam"`
+		XcamStatus string `json:"xcam_status"`
+	} `json:"print"`
+}
@@ -8,8 +8,12 @@ type MessageType string
 
 const (
 	Print   MessageType = "print"
-	System              = "system"
-	Pushing             = "pushing"
+	System  MessageType = "system"
+	Pushing MessageType = "pushing"
+	Info    MessageType = "info"
+	Upgrade MessageType = "upgrade"
+	Camera  MessageType = "camera"
+	Xcam    MessageType = "xcam"
 )
 
 type Command struct {
@@ -51,6 +55,11 @@ func (c *Command) AddParamField(value interface{}) *Command {
 	return c
 }
 
+// Field returns the value of the field with the given key, or nil if it is not set.
+func (c *Command) Field(key string) interface{} {
+	return c.fields[key]
+}
+
 func (c *Command) AddIdField(id string) *Command {
 	c.AddField("sequence_id", id)
 
@@ -24,6 +24,12 @@ func TestCommand_AddParamField(t *testing.T) {
 	assert.Equal(t, "value", cmd.fields["param"])
 }
 
+func TestCommand_Field(t *testing.T) {
+	cmd := NewCommand(Camera).AddCommandField("ipcam_record_set")
+	assert.Equal(t, "ipcam_record_set", cmd.Field("command"))
+	assert.Nil(t, cmd.Field("missing"))
+}
+
 func TestCommand_JSON(t *testing.T) {
 	cmd := NewCommand(Print)
 	cmd.AddCommandField("testCommandField")
@@ -0,0 +1,187 @@
+package mqtt
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// The client reconnects on its own rather than through paho so that the delay
+// between attempts follows ClientConfig.Backoff. Every connection, first or not,
+// resubscribes to the reports of all printers and requests a full report from each
+// since deltas may have been missed while disconnected.
+
+const subscribeAttempts = 5
+
+// ConnectionState is the state of the connection to the MQTT broker.
+type ConnectionState int
+
+const (
+	Disconnected ConnectionState = iota
+	Connecting
+	Connected
+	Reconnecting
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	default:
+		return "unknown"
+	}
+}
+
+// Backoff returns the delay before a retry, attempt starting at 1.
+type Backoff func(attempt int) time.Duration
+
+// DefaultBackoff is used when ClientConfig.Backoff is nil.
+var DefaultBackoff = ExponentialBackoff(time.Second, 2*time.Minute)
+
+// ExponentialBackoff returns a Backoff starting at base and doubling on every
+// attempt up to max.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max)
+	}
+}
+
+// State returns the state of the connection to the broker.
+func (c *Client) State() ConnectionState {
+	return ConnectionState(c.state.Load())
+}
+
+// Reconnects returns the number of times the client reconnected to the broker after
+// losing the connection.
+func (c *Client) Reconnects() uint64 {
+	return c.reconnects.Load()
+}
+
+func (c *Client) setState(state ConnectionState) {
+	c.state.Store(int32(state))
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	if c.config.Backoff != nil {
+		return c.config.Backoff(attempt)
+	}
+	return DefaultBackoff(attempt)
+}
+
+// wait sleeps for the backoff of the given attempt and reports whether the client
+// is still in use afterwards.
+func (c *Client) wait(attempt int) bool {
+	if c.closed() {
+		return false
+	}
+
+	timer := time.NewTimer(c.backoff(attempt))
+	defer timer.Stop()
+
+	// Both cases may be ready at once, in which case select picks either.
+	select {
+	case <-timer.C:
+		return !c.closed()
+	case <-c.doneChan:
+		return false
+	}
+}
+
+// closed reports whether Disconnect was called.
+func (c *Client) closed() bool {
+	select {
+	case <-c.doneChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// onConnect subscribes to the reports of every printer and requests a full report
+// from each. Printers are handled in parallel so that one failing subscription does
+// not hold back the others for the length of its retries.
+func (c *Client) onConnect(client paho.Client) {
+	c.setState(Connected)
+
+	var wg sync.WaitGroup
+	for _, serial := range c.Serials() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.subscribeWithRetry(client, serial); err != nil {
+				log.Println(err)
+				return
+			}
+			if err := c.requestPushAll(serial); err != nil {
+				log.Printf("Failed to request full report from %s: %v", serial, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// subscribeWithRetry subscribes to the reports of a printer, retrying while the
+// connection is open.
+func (c *Client) subscribeWithRetry(client paho.Client, serial string) error {
+	var err error
+	for attempt := 1; attempt <= subscribeAttempts; attempt++ {
+		if err = c.subscribe(client, serial); err == nil {
+			return nil
+		}
+		if attempt == subscribeAttempts || !client.IsConnectionOpen() || !c.wait(attempt) {
+			break
+		}
+	}
+	return err
+}
+
+func (c *Client) onConnectionLost(client paho.Client, err error) {
+	log.Printf("Connection lost: %v", err)
+	c.setState(Reconnecting)
+	go c.reconnect(client)
+}
+
+// reconnect connects to the broker again, waiting for the backoff between attempts,
+// until it succeeds or the client is disconnected.
+func (c *Client) reconnect(client paho.Client) {
+	for attempt := 1; c.wait(attempt); attempt++ {
+		connected, err := c.reconnectOnce(client)
+		if err != nil {
+			log.Printf("Reconnection attempt %d failed: %v", attempt, err)
+			continue
+		}
+		if connected {
+			c.reconnects.Add(1)
+			log.Println("Reconnected to MQTT broker")
+		}
+		return
+	}
+}
+
+// reconnectOnce makes a connection attempt unless the client was disconnected in
+// the meantime, which Disconnect cannot do while the attempt is in progress.
+func (c *Client) reconnectOnce(client paho.Client) (bool, error) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.closed() {
+		return false, nil
+	}
+	token := client.Connect()
+	if token.Wait() && token.Error() != nil {
+		return false, token.Error()
+	}
+	return true, nil
+}
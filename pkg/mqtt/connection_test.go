@@ -0,0 +1,185 @@
+package mqtt
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeToken struct {
+	err error
+}
+
+func (t fakeToken) Wait() bool                     { return true }
+func (t fakeToken) WaitTimeout(time.Duration) bool { return true }
+func (t fakeToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+func (t fakeToken) Error() error { return t.err }
+
+// fakeBroker implements the parts of paho.Client used by the client; calling any
+// other method panics.
+type fakeBroker struct {
+	paho.Client
+
+	mu             sync.Mutex
+	connectErrors  []error        // Results of the next Connect calls, nil once exhausted
+	subscribeFails map[string]int // Number of failed subscriptions left, by topic
+	connects       int
+	subscriptions  map[string]int // Subscription attempts by topic
+	published      []string
+}
+
+func (b *fakeBroker) Connect() paho.Token {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connects++
+	if len(b.connectErrors) == 0 {
+		return fakeToken{}
+	}
+	err := b.connectErrors[0]
+	b.connectErrors = b.connectErrors[1:]
+	return fakeToken{err: err}
+}
+
+func (b *fakeBroker) Disconnect(uint) {}
+
+func (b *fakeBroker) IsConnectionOpen() bool { return true }
+
+func (b *fakeBroker) Subscribe(topic string, _ byte, _ paho.MessageHandler) paho.Token {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscriptions == nil {
+		b.subscriptions = make(map[string]int)
+	}
+	b.subscriptions[topic]++
+	if b.subscribeFails[topic] > 0 {
+		b.subscribeFails[topic]--
+		return fakeToken{err: errors.New("subscribe refused")}
+	}
+	return fakeToken{}
+}
+
+func (b *fakeBroker) Publish(topic string, _ byte, _ bool, _ interface{}) paho.Token {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, topic)
+	return fakeToken{}
+}
+
+// backoffRecorder is a Backoff without delay recording the attempts it is asked for.
+type backoffRecorder struct {
+	mu       sync.Mutex
+	attempts []int
+}
+
+func (r *backoffRecorder) backoff(attempt int) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, attempt)
+	return 0
+}
+
+func (r *backoffRecorder) recorded() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Sorted(slices.Values(r.attempts))
+}
+
+func newFakeClient(broker *fakeBroker, serials ...string) (*Client, *backoffRecorder) {
+	recorder := &backoffRecorder{}
+	client := NewClient(&ClientConfig{Serials: serials, Backoff: recorder.backoff})
+	client.client = broker
+	return client, recorder
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 5*time.Second)
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 4*time.Second, backoff(3))
+	assert.Equal(t, 5*time.Second, backoff(4))
+	assert.Equal(t, 5*time.Second, backoff(100))
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	broker := &fakeBroker{connectErrors: []error{errors.New("refused"), errors.New("refused")}}
+	client, attempts := newFakeClient(broker)
+	assert.Equal(t, Disconnected, client.State())
+
+	client.reconnect(broker)
+	assert.Equal(t, 3, broker.connects)
+	assert.Equal(t, []int{1, 2, 3}, attempts.recorded())
+	assert.Equal(t, uint64(1), client.Reconnects())
+}
+
+func TestClient_ReconnectStopsOnDisconnect(t *testing.T) {
+	broker := &fakeBroker{}
+	client, _ := newFakeClient(broker)
+	client.Disconnect()
+
+	client.reconnect(broker)
+	assert.Zero(t, broker.connects)
+	assert.Zero(t, client.Reconnects())
+}
+
+func TestClient_DisconnectTwice(t *testing.T) {
+	broker := &fakeBroker{}
+	client, _ := newFakeClient(broker)
+
+	client.Disconnect()
+	assert.NotPanics(t, client.Disconnect)
+	assert.Equal(t, Disconnected, client.State())
+}
+
+func TestClient_ReconnectDisconnectedDuringBackoff(t *testing.T) {
+	broker := &fakeBroker{}
+	client, _ := newFakeClient(broker)
+	client.config.Backoff = func(int) time.Duration {
+		client.Disconnect()
+		return 0
+	}
+
+	// The timer and doneChan are both ready once the backoff elapses.
+	client.reconnect(broker)
+	assert.Zero(t, broker.connects)
+	assert.Zero(t, client.Reconnects())
+}
+
+func TestClient_OnConnect(t *testing.T) {
+	broker := &fakeBroker{subscribeFails: map[string]int{
+		"device/A/report": 2,
+		"device/B/report": subscribeAttempts,
+	}}
+	client, attempts := newFakeClient(broker, "A", "B", "C")
+
+	client.onConnect(broker)
+	assert.Equal(t, Connected, client.State())
+
+	// A failing subscription is retried and does not prevent the others.
+	assert.Equal(t, map[string]int{
+		"device/A/report": 3,
+		"device/B/report": subscribeAttempts,
+		"device/C/report": 1,
+	}, broker.subscriptions)
+	assert.Equal(t, []int{1, 1, 2, 2, 3, 4}, attempts.recorded())
+
+	// Every subscribed printer is asked for a full report.
+	require.ElementsMatch(t, []string{"device/A/request", "device/C/request"}, broker.published)
+	assert.False(t, client.needsRefresh("A", time.Now()))
+	assert.True(t, client.needsRefresh("B", time.Now()))
+}
+
+func TestConnectionState_String(t *testing.T) {
+	assert.Equal(t, "connected", Connected.String())
+	assert.Equal(t, "reconnecting", Reconnecting.String())
+	assert.Equal(t, "unknown", ConnectionState(42).String())
+}
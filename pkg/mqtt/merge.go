@@ -0,0 +1,215 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// Printers only send the fields that changed since their last report, so every
+// report is decoded on top of the state kept for that printer. encoding/json only
+// touches the keys present in the payload, so an explicit 0, false or "" always
+// overwrites the previous value while missing keys keep it.
+//
+// Reports are decoded into a copy of the previous Message. Before decoding, every
+// slice the decoder could write into is detached from the snapshots handed out by
+// Client.Data, and AMS units and trays are merged by id (see AmsUnits.UnmarshalJSON)
+// rather than by position.
+
+// detach gives m its own copy of every slice the JSON decoder could write into.
+func (m *Message) detach() {
+	p := &m.Print
+	p.FilamBak = slices.Clone(p.FilamBak)
+	p.Hms = slices.Clone(p.Hms)
+	p.LightsReport = slices.Clone(p.LightsReport)
+	p.SObj = slices.Clone(p.SObj)
+	p.Stg = slices.Clone(p.Stg)
+	p.VtTray.Cols = slices.Clone(p.VtTray.Cols)
+}
+
+// UnmarshalJSON replaces the module list, as every get_version reply is complete.
+func (v *VersionModules) UnmarshalJSON(data []byte) error {
+	var modules []VersionModule
+	err := json.Unmarshal(data, &modules)
+	if !isTypeError(err) {
+		return err
+	}
+	*v = modules
+	return err
+}
+
+// amsUnitPatch and amsTrayPatch mirror AmsUnit and AmsTray with patchField fields,
+// so a single decoding pass tells which keys each element of the array carries.
+type amsUnitPatch struct {
+	Humidity patchField[string]         `json:"humidity"`
+	ID       string                     `json:"id"`
+	Info     patchField[string]         `json:"info"`
+	Temp     patchField[string]         `json:"temp"`
+	Tray     patchField[[]amsTrayPatch] `json:"tray"`
+}
+
+type amsTrayPatch struct {
+	ID            string               `json:"id"`
+	BedTemp       patchField[string]   `json:"bed_temp"`
+	BedTempType   patchField[string]   `json:"bed_temp_type"`
+	Cols          patchField[[]string] `json:"cols"`
+	DryingTemp    patchField[string]   `json:"drying_temp"`
+	DryingTime    patchField[string]   `json:"drying_time"`
+	NozzleTempMax patchField[string]   `json:"nozzle_temp_max"`
+	NozzleTempMin patchField[string]   `json:"nozzle_temp_min"`
+	Remain        patchField[int]      `json:"remain"`
+	TagUID        patchField[string]   `json:"tag_uid"`
+	TrayColor     patchField[string]   `json:"tray_color"`
+	TrayDiameter  patchField[string]   `json:"tray_diameter"`
+	TrayIDName    patchField[string]   `json:"tray_id_name"`
+	TrayInfoIdx   patchField[string]   `json:"tray_info_idx"`
+	TraySubBrands patchField[string]   `json:"tray_sub_brands"`
+	TrayType      patchField[string]   `json:"tray_type"`
+	TrayUUID      patchField[string]   `json:"tray_uuid"`
+	TrayWeight    patchField[string]   `json:"tray_weight"`
+	XcamInfo      patchField[string]   `json:"xcam_info"`
+}
+
+// patchField is a field of a patch. It is present if the report carried its key,
+// and ok if the value also had the right type. A value that does not decode is
+// ignored, so the field keeps its previous value.
+type patchField[T any] struct {
+	value   T
+	present bool
+	ok      bool
+}
+
+// UnmarshalJSON never fails: an error returned from here would stop encoding/json
+// from decoding the rest of the report.
+func (f *patchField[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	f.present = true
+	f.ok = json.Unmarshal(data, &f.value) == nil
+	return nil
+}
+
+// UnmarshalJSON merges the reported AMS units with the existing ones. The report
+// decides which units exist and in which order; each unit is merged with the
+// existing unit of the same id, and its trays are merged the same way. A unit list
+// that does not decode keeps the existing units.
+func (u *AmsUnits) UnmarshalJSON(data []byte) error {
+	var patches []amsUnitPatch
+	if err := json.Unmarshal(data, &patches); err != nil {
+		return ignoreTypeError(err)
+	}
+	if patches == nil {
+		*u = nil
+		return nil
+	}
+
+	existing := *u
+	units := make(AmsUnits, 0, len(patches))
+
+	for _, patch := range patches {
+		var unit AmsUnit
+		if i := slices.IndexFunc(existing, func(e AmsUnit) bool { return e.ID == patch.ID }); i >= 0 {
+			unit = existing[i]
+		}
+		unit.ID = patch.ID
+
+		set(&unit.Humidity, patch.Humidity)
+		set(&unit.Info, patch.Info)
+		set(&unit.Temp, patch.Temp)
+		if patch.Tray.ok {
+			unit.Tray = mergeAmsTrays(unit.Tray, patch.Tray.value)
+		}
+
+		units = append(units, unit)
+	}
+
+	*u = units
+	return nil
+}
+
+// UnmarshalJSON merges the reported trays with the existing ones by id, see
+// mergeAmsTrays. A tray list that does not decode keeps the existing trays.
+func (t *AmsTrays) UnmarshalJSON(data []byte) error {
+	var patches []amsTrayPatch
+	if err := json.Unmarshal(data, &patches); err != nil {
+		return ignoreTypeError(err)
+	}
+	if patches == nil {
+		*t = nil
+		return nil
+	}
+
+	*t = mergeAmsTrays(*t, patches)
+	return nil
+}
+
+// mergeAmsTrays merges reported trays with the existing ones by id. A tray reported
+// with nothing but its id has been emptied and is reset.
+func mergeAmsTrays(existing AmsTrays, patches []amsTrayPatch) AmsTrays {
+	trays := make(AmsTrays, 0, len(patches))
+
+	for _, patch := range patches {
+		var tray AmsTray
+		if i := slices.IndexFunc(existing, func(e AmsTray) bool { return e.ID == patch.ID }); i >= 0 && !patch.idOnly() {
+			tray = existing[i]
+		}
+		tray.ID = patch.ID
+
+		set(&tray.BedTemp, patch.BedTemp)
+		set(&tray.BedTempType, patch.BedTempType)
+		set(&tray.Cols, patch.Cols)
+		set(&tray.DryingTemp, patch.DryingTemp)
+		set(&tray.DryingTime, patch.DryingTime)
+		set(&tray.NozzleTempMax, patch.NozzleTempMax)
+		set(&tray.NozzleTempMin, patch.NozzleTempMin)
+		set(&tray.Remain, patch.Remain)
+		set(&tray.TagUID, patch.TagUID)
+		set(&tray.TrayColor, patch.TrayColor)
+		set(&tray.TrayDiameter, patch.TrayDiameter)
+		set(&tray.TrayIDName, patch.TrayIDName)
+		set(&tray.TrayInfoIdx, patch.TrayInfoIdx)
+		set(&tray.TraySubBrands, patch.TraySubBrands)
+		set(&tray.TrayType, patch.TrayType)
+		set(&tray.TrayUUID, patch.TrayUUID)
+		set(&tray.TrayWeight, patch.TrayWeight)
+		set(&tray.XcamInfo, patch.XcamInfo)
+
+		trays = append(trays, tray)
+	}
+
+	return trays
+}
+
+// idOnly reports whether the tray was reported with nothing but its id.
+func (p amsTrayPatch) idOnly() bool {
+	return !(p.BedTemp.present || p.BedTempType.present || p.Cols.present ||
+		p.DryingTemp.present || p.DryingTime.present || p.NozzleTempMax.present ||
+		p.NozzleTempMin.present || p.Remain.present || p.TagUID.present ||
+		p.TrayColor.present || p.TrayDiameter.present || p.TrayIDName.present ||
+		p.TrayInfoIdx.present || p.TraySubBrands.present || p.TrayType.present ||
+		p.TrayUUID.present || p.TrayWeight.present || p.XcamInfo.present)
+}
+
+// isTypeError reports whether err is nil or only a type mismatch, in which case the
+// decoder skipped the offending value and everything else was decoded.
+func isTypeError(err error) bool {
+	var typeErr *json.UnmarshalTypeError
+	return err == nil || errors.As(err, &typeErr)
+}
+
+// ignoreTypeError drops type mismatches, which a custom UnmarshalJSON must not
+// return as they stop encoding/json from decoding the rest of the report.
+func ignoreTypeError(err error) error {
+	if isTypeError(err) {
+		return nil
+	}
+	return err
+}
+
+// set stores the value of field in dst if the report carried it.
+func set[T any](dst *T, field patchField[T]) {
+	if field.ok {
+		*dst = field.value
+	}
+}
@@ -0,0 +1,197 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fullReport = `{"print":{
+	"command":"push_status","sequence_id":"1",
+	"bed_temper":60.5,"bed_target_temper":60,"nozzle_temper":220,"nozzle_target_temper":220,
+	"cooling_fan_speed":"15","big_fan1_speed":"10","big_fan2_speed":"5","heatbreak_fan_speed":"15",
+	"gcode_state":"RUNNING","mc_percent":42,"mc_remaining_time":73,"sdcard":true,
+	"ipcam":{"ipcam_dev":"1","ipcam_record":"enable","resolution":"1080p","timelapse":"disable"},
+	"lights_report":[{"node":"chamber_light","mode":"on"}],
+	"ams":{"ams_exist_bits":"1","tray_exist_bits":"f","tray_now":"1","tray_tar":"1","version":4,
+		"ams":[{"id":"0","humidity":"4","temp":"24.5","tray":[
+			{"id":"0","remain":80,"tray_type":"PLA","tray_color":"FFFFFFFF","tray_weight":"1000","cols":["FFFFFFFF"]},
+			{"id":"1","remain":50,"tray_type":"PETG","tray_color":"000000FF","tray_weight":"1000"},
+			{"id":"2","remain":10,"tray_type":"PLA","tray_color":"FF0000FF","tray_weight":"1000"},
+			{"id":"3","remain":-1,"tray_type":"ABS","tray_color":"00FF00FF","tray_weight":"1000"}
+		]}]}
+}}`
+
+func newTestClient() *Client {
+	return &Client{
+		data: make(map[string]*report),
+	}
+}
+
+func TestClient_Ingest(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		check  func(t *testing.T, m Message)
+	}{
+		{
+			name:   "fan stops",
+			report: `{"print":{"command":"push_status","sequence_id":"2","cooling_fan_speed":"0"}}`,
+			check: func(t *testing.T, m Message) {
+				assert.Equal(t, "0", m.Print.CoolingFanSpeed)
+				assert.Equal(t, "10", m.Print.BigFan1Speed)
+			},
+		},
+		{
+			name:   "heater switched off",
+			report: `{"print":{"command":"push_status","sequence_id":"2","nozzle_target_temper":0,"bed_temper":0}}`,
+			check: func(t *testing.T, m Message) {
+				assert.Equal(t, 0.0, m.Print.NozzleTargetTemper)
+				assert.Equal(t, 0.0, m.Print.BedTemper)
+				assert.Equal(t, 220.0, m.Print.NozzleTemper)
+			},
+		},
+		{
+			name:   "sd card removed",
+			report: `{"print":{"command":"push_status","sequence_id":"2","sdcard":false}}`,
+			check: func(t *testing.T, m Message) {
+				assert.False(t, m.Print.Sdcard)
+				assert.Equal(t, "RUNNING", m.Print.GcodeState)
+			},
+		},
+		{
+			name:   "partial nested object",
+			report: `{"print":{"command":"push_status","sequence_id":"2","ipcam":{"timelapse":"enable"}}}`,
+			check: func(t *testing.T, m Message) {
+				assert.Equal(t, "enable", m.Print.Ipcam.Timelapse)
+				assert.Equal(t, "1080p", m.Print.Ipcam.Resolution)
+			},
+		},
+		{
+			name: "tray emptied",
+			report: `{"print":{"command":"push_status","sequence_id":"2","ams":{"tray_exist_bits":"d",
+				"ams":[{"id":"0","humidity":"4","temp":"24.5","tray":[{"id":"0","remain":80},{"id":"1"},{"id":"2","remain":9},{"id":"3"}]}]}}}`,
+			check: func(t *testing.T, m Message) {
+				trays := m.Print.Ams.Ams[0].Tray
+				require.Len(t, trays, 4)
+				assert.Equal(t, "PLA", trays[0].TrayType)
+				assert.Equal(t, "", trays[1].TrayType)
+				assert.Equal(t, 0, trays[1].Remain)
+				assert.Equal(t, "PLA", trays[2].TrayType)
+				assert.Equal(t, 9, trays[2].Remain)
+				assert.Equal(t, "", trays[3].TrayType)
+				assert.Equal(t, "d", m.Print.Ams.TrayExistBits)
+				assert.Equal(t, "1", m.Print.Ams.TrayNow)
+			},
+		},
+		{
+			name: "tray order follows report",
+			report: `{"print":{"command":"push_status","sequence_id":"2","ams":{
+				"ams":[{"id":"0","tray":[{"id":"3","remain":5},{"id":"0","remain":70}]}]}}}`,
+			check: func(t *testing.T, m Message) {
+				trays := m.Print.Ams.Ams[0].Tray
+				require.Len(t, trays, 2)
+				assert.Equal(t, "3", trays[0].ID)
+				assert.Equal(t, "ABS", trays[0].TrayType)
+				assert.Equal(t, 5, trays[0].Remain)
+				assert.Equal(t, "PLA", trays[1].TrayType)
+				assert.Equal(t, "24.5", m.Print.Ams.Ams[0].Temp)
+			},
+		},
+		{
+			name: "second ams unit attached",
+			report: `{"print":{"command":"push_status","sequence_id":"2","ams":{"ams_exist_bits":"3",
+				"ams":[{"id":"0"},{"id":"1","humidity":"5","temp":"22.0","tray":[{"id":"0","tray_type":"TPU"}]}]}}}`,
+			check: func(t *testing.T, m Message) {
+				units := m.Print.Ams.Ams
+				require.Len(t, units, 2)
+				assert.Equal(t, "0", units[0].ID)
+				assert.Equal(t, "1", units[1].ID)
+				assert.Equal(t, "TPU", units[1].Tray[0].TrayType)
+
+				// A unit reported with nothing but its id keeps its state.
+				require.Len(t, units[0].Tray, 4)
+				assert.Equal(t, "PLA", units[0].Tray[0].TrayType)
+				assert.Equal(t, "4", units[0].Humidity)
+			},
+		},
+		{
+			name:   "objects skipped",
+			report: `{"print":{"command":"push_status","sequence_id":"2","s_obj":[143,211]}}`,
+			check: func(t *testing.T, m Message) {
+				assert.Equal(t, []int{143, 211}, m.Print.SObj)
+				assert.Equal(t, "RUNNING", m.Print.GcodeState)
+			},
+		},
+		{
+			name:   "lights replaced wholesale",
+			report: `{"print":{"command":"push_status","sequence_id":"2","lights_report":[{"node":"chamber_light","mode":"off"}]}}`,
+			check: func(t *testing.T, m Message) {
+				require.Len(t, m.Print.LightsReport, 1)
+				assert.Equal(t, "off", m.Print.LightsReport[0].Mode)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient()
+			client.ingest("SERIAL", []byte(fullReport))
+			client.ingest("SERIAL", []byte(tt.report))
+			tt.check(t, client.Data("SERIAL"))
+		})
+	}
+}
+
+func TestClient_IngestKeepsSerialsApart(t *testing.T) {
+	client := newTestClient()
+	client.ingest("A", []byte(fullReport))
+	client.ingest("B", []byte(`{"print":{"bed_temper":25}}`))
+
+	assert.Equal(t, 60.5, client.Data("A").Print.BedTemper)
+	assert.Equal(t, 25.0, client.Data("B").Print.BedTemper)
+	assert.Equal(t, "", client.Data("B").Print.GcodeState)
+}
+
+func TestClient_IngestInvalidPayload(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(fullReport))
+	client.ingest("SERIAL", []byte(`not json`))
+	client.ingest("SERIAL", []byte(`[1,2,3]`))
+
+	assert.Equal(t, 42, client.Data("SERIAL").Print.McPercent)
+}
+
+func TestClient_IngestTypeMismatch(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(fullReport))
+	client.ingest("SERIAL", []byte(`{"print":{"mc_percent":"oops","mc_remaining_time":12}}`))
+
+	assert.Equal(t, 42, client.Data("SERIAL").Print.McPercent)
+	assert.Equal(t, 12, client.Data("SERIAL").Print.McRemainingTime)
+	assert.Equal(t, "RUNNING", client.Data("SERIAL").Print.GcodeState)
+}
+
+func TestClient_IngestAmsTypeMismatch(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(fullReport))
+	client.ingest("SERIAL", []byte(`{"print":{"ams":{"ams":[{"id":"0","humidity":5,"temp":"25.0",
+		"tray":[{"id":"0","tray_type":7},{"id":"1"},{"id":"2","remain":"x","tray_color":"0000FFFF"},{"id":"3"}]}]},
+		"mc_percent":50}}`))
+
+	m := client.Data("SERIAL")
+	unit := m.Print.Ams.Ams[0]
+	assert.Equal(t, "4", unit.Humidity)
+	assert.Equal(t, "25.0", unit.Temp)
+	require.Len(t, unit.Tray, 4)
+
+	// A tray whose only other field failed to decode is kept, not reset.
+	assert.Equal(t, "PLA", unit.Tray[0].TrayType)
+	assert.Equal(t, 80, unit.Tray[0].Remain)
+	assert.Equal(t, 10, unit.Tray[2].Remain)
+	assert.Equal(t, "0000FFFF", unit.Tray[2].TrayColor)
+
+	// The rest of the report is still applied.
+	assert.Equal(t, 50, m.Print.McPercent)
+}
@@ -2,70 +2,34 @@ package mqtt
 
 type Message struct {
 	Print struct {
-		Ams struct {
-			Ams []struct {
-				Humidity string `json:"humidity"`
-				ID       string `json:"id"`
-				Temp     string `json:"temp"`
-				Tray     []struct {
-					ID            string   `json:"id"`
-					BedTemp       string   `json:"bed_temp,omitempty"`
-					BedTempType   string   `json:"bed_temp_type,omitempty"`
-					Cols          []string `json:"cols,omitempty"`
-					DryingTemp    string   `json:"drying_temp,omitempty"`
-					DryingTime    string   `json:"drying_time,omitempty"`
-					NozzleTempMax string   `json:"nozzle_temp_max,omitempty"`
-					NozzleTempMin string   `json:"nozzle_temp_min,omitempty"`
-					Remain        int      `json:"remain,omitempty"`
-					TagUID        string   `json:"tag_uid,omitempty"`
-					TrayColor     string   `json:"tray_color,omitempty"`
-					TrayDiameter  string   `json:"tray_diameter,omitempty"`
-					TrayIDName    string   `json:"tray_id_name,omitempty"`
-					TrayInfoIdx   string   `json:"tray_info_idx,omitempty"`
-					TraySubBrands string   `json:"tray_sub_brands,omitempty"`
-					TrayType      string   `json:"tray_type,omitempty"`
-					TrayUUID      string   `json:"tray_uuid,omitempty"`
-					TrayWeight    string   `json:"tray_weight,omitempty"`
-					XcamInfo      string   `json:"xcam_info,omitempty"`
-				} `json:"tray"`
-			} `json:"ams"`
-			AmsExistBits     string `json:"ams_exist_bits"`
-			InsertFlag       bool   `json:"insert_flag"`
-			PowerOnFlag      bool   `json:"power_on_flag"`
-			TrayExistBits    string `json:"tray_exist_bits"`
-			TrayIsBblBits    string `json:"tray_is_bbl_bits"`
-			TrayNow          string `json:"tray_now"`
-			TrayReadDoneBits string `json:"tray_read_done_bits"`
-			TrayReadingBits  string `json:"tray_reading_bits"`
-			TrayTar          string `json:"tray_tar"`
-			Version          int    `json:"version"`
-		} `json:"ams"`
-		AmsRfidStatus           int     `json:"ams_rfid_status"`
-		AmsStatus               int     `json:"ams_status"`
-		AuxPartFan              bool    `json:"aux_part_fan"`
-		BedTargetTemper         float64 `json:"bed_target_temper"`
-		BedTemper               float64 `json:"bed_temper"`
-		BigFan1Speed            string  `json:"big_fan1_speed"`
-		BigFan2Speed            string  `json:"big_fan2_speed"`
-		ChamberTemper           float64 `json:"chamber_temper"`
-		Command                 string  `json:"command"`
-		CoolingFanSpeed         string  `json:"cooling_fan_speed"`
-		FailReason              string  `json:"fail_reason"`
-		FanGear                 int     `json:"fan_gear"`
-		FilamBak                []any   `json:"filam_bak"`
-		ForceUpgrade            bool    `json:"force_upgrade"`
-		GcodeFile               string  `json:"gcode_file"`
-		GcodeFilePreparePercent string  `json:"gcode_file_prepare_percent"`
-		GcodeStartTime          string  `json:"gcode_start_time"`
-		GcodeState              string  `json:"gcode_state"`
-		HeatbreakFanSpeed       string  `json:"heatbreak_fan_speed"`
-		Hms                     []any   `json:"hms"`
-		HomeFlag                int     `json:"home_flag"`
-		HwSwitchState           int     `json:"hw_switch_state"`
+		Ams                     AmsReport `json:"ams"`
+		AmsRfidStatus           int       `json:"ams_rfid_status"`
+		AmsStatus               int       `json:"ams_status"`
+		AuxPartFan              bool      `json:"aux_part_fan"`
+		BedTargetTemper         float64   `json:"bed_target_temper"`
+		BedTemper               float64   `json:"bed_temper"`
+		BigFan1Speed            string    `json:"big_fan1_speed"`
+		BigFan2Speed            string    `json:"big_fan2_speed"`
+		ChamberTemper           float64   `json:"chamber_temper"`
+		Command                 string    `json:"command"`
+		CoolingFanSpeed         string    `json:"cooling_fan_speed"`
+		FailReason              string    `json:"fail_reason"`
+		FanGear                 int       `json:"fan_gear"`
+		FilamBak                []any     `json:"filam_bak"`
+		ForceUpgrade            bool      `json:"force_upgrade"`
+		GcodeFile               string    `json:"gcode_file"`
+		GcodeFilePreparePercent string    `json:"gcode_file_prepare_percent"`
+		GcodeStartTime          string    `json:"gcode_start_time"`
+		GcodeState              string    `json:"gcode_state"`
+		HeatbreakFanSpeed       string    `json:"heatbreak_fan_speed"`
+		Hms                     []any     `json:"hms"`
+		HomeFlag                int       `json:"home_flag"`
+		HwSwitchState           int       `json:"hw_switch_state"`
 		Ipcam                   struct {
 			IpcamDev    string `json:"ipcam_dev"`
 			IpcamRecord string `json:"ipcam_record"`
 			Resolution  string `json:"resolution"`
+			RtspURL     string `json:"rtsp_url"`
 			Timelapse   string `json:"timelapse"`
 		} `json:"ipcam"`
 		LayerNum     int    `json:"layer_num"`
@@ -98,6 +62,7 @@ type Message struct {
 		QueueNumber      int    `json:"queue_number"`
 		Sdcard           bool   `json:"sdcard"`
 		SequenceID       string `json:"sequence_id"`
+		SObj             []int  `json:"s_obj"`
 		SpdLvl           int    `json:"spd_lvl"`
 		SpdMag           int    `json:"spd_mag"`
 		Stg              []any  `json:"stg"`
@@ -134,28 +99,8 @@ type Message struct {
 			TimeRemaining int    `json:"time_remaining"`
 			TroubleID     string `json:"trouble_id"`
 		} `json:"upload"`
-		VtTray struct {
-			BedTemp       string   `json:"bed_temp"`
-			BedTempType   string   `json:"bed_temp_type"`
-			Cols          []string `json:"cols"`
-			DryingTemp    string   `json:"drying_temp"`
-			DryingTime    string   `json:"drying_time"`
-			ID            string   `json:"id"`
-			NozzleTempMax string   `json:"nozzle_temp_max"`
-			NozzleTempMin string   `json:"nozzle_temp_min"`
-			Remain        int      `json:"remain"`
-			TagUID        string   `json:"tag_uid"`
-			TrayColor     string   `json:"tray_color"`
-			TrayDiameter  string   `json:"tray_diameter"`
-			TrayIDName    string   `json:"tray_id_name"`
-			TrayInfoIdx   string   `json:"tray_info_idx"`
-			TraySubBrands string   `json:"tray_sub_brands"`
-			TrayType      string   `json:"tray_type"`
-			TrayUUID      string   `json:"tray_uuid"`
-			TrayWeight    string   `json:"tray_weight"`
-			XcamInfo      string   `json:"xcam_info"`
-		} `json:"vt_tray"`
-		WifiSignal string `json:"wifi_signal"`
+		VtTray     AmsTray `json:"vt_tray"`
+		WifiSignal string  `json:"wifi_signal"`
 		Xcam       struct {
 			AllowSkipParts           bool   `json:"allow_skip_parts"`
 			BuildplateMarkerDetector bool   `json:"buildplate_marker_detector"`
@@ -167,4 +112,112 @@ type Message struct {
 		} `json:"xcam"`
 		XcamStatus string `json:"xcam_status"`
 	} `json:"print"`
+	Info    InfoMessage    `json:"info"`
+	System  SystemMessage  `json:"system"`
+	Upgrade UpgradeMessage `json:"upgrade"`
+}
+
+// AmsReport is the "ams" object of a print report.
+type AmsReport struct {
+	Ams              AmsUnits `json:"ams"`
+	AmsExistBits     string   `json:"ams_exist_bits"`
+	InsertFlag       bool     `json:"insert_flag"`
+	PowerOnFlag      bool     `json:"power_on_flag"`
+	TrayExistBits    string   `json:"tray_exist_bits"`
+	TrayIsBblBits    string   `json:"tray_is_bbl_bits"`
+	TrayNow          string   `json:"tray_now"`
+	TrayReadDoneBits string   `json:"tray_read_done_bits"`
+	TrayReadingBits  string   `json:"tray_reading_bits"`
+	TrayTar          string   `json:"tray_tar"`
+	Version          int      `json:"version"`
+}
+
+// AmsUnit is a single AMS unit in an AmsReport.
+type AmsUnit struct {
+	Humidity string   `json:"humidity"`
+	ID       string   `json:"id"`
+	Info     string   `json:"info,omitempty"`
+	Temp     string   `json:"temp"`
+	Tray     AmsTrays `json:"tray"`
+}
+
+// AmsUnits is the list of AMS units in an AmsReport, merged by id when decoded.
+type AmsUnits []AmsUnit
+
+// AmsTrays is the list of trays in an AmsUnit, merged by id when decoded.
+type AmsTrays []AmsTray
+
+// AmsTray is a filament slot, either in an AMS unit or the external spool (vt_tray).
+type AmsTray struct {
+	ID            string   `json:"id"`
+	BedTemp       string   `json:"bed_temp,omitempty"`
+	BedTempType   string   `json:"bed_temp_type,omitempty"`
+	Cols          []string `json:"cols,omitempty"`
+	DryingTemp    string   `json:"drying_temp,omitempty"`
+	DryingTime    string   `json:"drying_time,omitempty"`
+	NozzleTempMax string   `json:"nozzle_temp_max,omitempty"`
+	NozzleTempMin string   `json:"nozzle_temp_min,omitempty"`
+	Remain        int      `json:"remain,omitempty"`
+	TagUID        string   `json:"tag_uid,omitempty"`
+	TrayColor     string   `json:"tray_color,omitempty"`
+	TrayDiameter  string   `json:"tray_diameter,omitempty"`
+	TrayIDName    string   `json:"tray_id_name,omitempty"`
+	TrayInfoIdx   string   `json:"tray_info_idx,omitempty"`
+	TraySubBrands string   `json:"tray_sub_brands,omitempty"`
+	TrayType      string   `json:"tray_type,omitempty"`
+	TrayUUID      string   `json:"tray_uuid,omitempty"`
+	TrayWeight    string   `json:"tray_weight,omitempty"`
+	XcamInfo      string   `json:"xcam_info,omitempty"`
+}
+
+// InfoMessage is the "info" object sent in reply to info commands such as get_version.
+type InfoMessage struct {
+	Command    string         `json:"command"`
+	SequenceID string         `json:"sequence_id"`
+	Module     VersionModules `json:"module"`
+	Result     string         `json:"result"`
+	Reason     string         `json:"reason"`
+}
+
+// VersionModules is the module list of a get_version reply. Each reply carries the
+// complete list, so it replaces the previous one when decoded.
+type VersionModules []VersionModule
+
+// VersionModule describes the firmware of one module of the printer (main board,
+// toolhead, AMS units...).
+type VersionModule struct {
+	Name        string `json:"name"`
+	ProjectName string `json:"project_name"`
+	SwVer       string `json:"sw_ver"`
+	HwVer       string `json:"hw_ver"`
+	Sn          string `json:"sn"`
+	LoaderVer   string `json:"loader_ver,omitempty"`
+	OtaVer      string `json:"ota_ver,omitempty"`
+	Flag        int    `json:"flag,omitempty"`
+}
+
+// SystemMessage is the "system" object sent in reply to system commands such as ledctrl.
+type SystemMessage struct {
+	Command      string `json:"command"`
+	SequenceID   string `json:"sequence_id"`
+	Result       string `json:"result"`
+	Reason       string `json:"reason"`
+	LedNode      string `json:"led_node,omitempty"`
+	LedMode      string `json:"led_mode,omitempty"`
+	AccessCode   string `json:"access_code,omitempty"`
+	LedOnTime    int    `json:"led_on_time,omitempty"`
+	LedOffTime   int    `json:"led_off_time,omitempty"`
+	LoopTimes    int    `json:"loop_times,omitempty"`
+	IntervalTime int    `json:"interval_time,omitempty"`
+}
+
+// UpgradeMessage is the "upgrade" object sent in reply to firmware upgrade commands.
+type UpgradeMessage struct {
+	Command    string `json:"command"`
+	SequenceID string `json:"sequence_id"`
+	Result     string `json:"result"`
+	Reason     string `json:"reason"`
+	Module     string `json:"module,omitempty"`
+	Version    string `json:"version,omitempty"`
+	URL        string `json:"url,omitempty"`
 }
@@ -2,12 +2,15 @@ package mqtt
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"log"
-	"reflect"
-	"regexp"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	paho "github.com/eclipse/paho.mqtt.golang"
@@ -19,6 +22,11 @@ const (
 	commandTopic   = "device/%s/request"
 	qos            = 0
 	updateInterval = 10 * time.Second
+
+	// DefaultPushAllInterval is the minimum time between two full reports requested
+	// from a printer. Bambu Lab advises against requesting them more often from the
+	// P1 series, whose boards struggle to keep up.
+	DefaultPushAllInterval = 5 * time.Minute
 )
 
 type ClientConfig struct {
@@ -27,18 +35,41 @@ type ClientConfig struct {
 	Serials    []string // List of serial numbers
 	Username   string
 	AccessCode string
-	Timeout    time.Duration
+	Timeout    time.Duration // Time allowed to connect to the broker, paho's default of 30s if zero
+
+	// A full report is requested from a printer when no report was received from it
+	// for PushAllInterval, at most once per PushAllInterval. Defaults to
+	// DefaultPushAllInterval.
+	PushAllInterval time.Duration
+
+	// Delay between reconnection and subscription attempts, DefaultBackoff if nil.
+	Backoff Backoff
+
+	// By default the broker certificate is verified, see tlsConfig.
+	TLSConfig          *tls.Config    // Used as-is when set, ignoring the fields below
+	RootCAs            *x509.CertPool // Replaces the default trust anchors
+	ServerSerial       string         // Serial the certificate must be issued to, for a printer's own broker
+	InsecureSkipVerify bool           // Disables certificate verification
 }
 
 type Client struct {
-	config      *ClientConfig
-	client      paho.Client
-	mutex       sync.Mutex
-	data        map[string]Message
-	lastUpdate  time.Time
-	messageChan chan paho.Message
-	doneChan    chan struct{}
-	ticker      *time.Ticker
+	config   *ClientConfig
+	client   paho.Client
+	mutex    sync.Mutex
+	data     map[string]*report
+	pushAll  map[string]time.Time // Time a full report was last requested, by serial
+	queues   []chan queuedMessage
+	doneChan chan struct{}
+	ticker   *time.Ticker
+
+	state      atomic.Int32 // ConnectionState
+	reconnects atomic.Uint64
+	connMu     sync.Mutex // Held by Disconnect and around each reconnection attempt
+
+	sequence    atomic.Uint64
+	waitersMu   sync.Mutex
+	waiters     map[waiterKey]chan json.RawMessage
+	fullReports map[string][]chan struct{} // Waiters for the next full report, by serial
 }
 
 func NewClient(config *ClientConfig) *Client {
@@ -47,15 +78,19 @@ func NewClient(config *ClientConfig) *Client {
 		SetClientID(clientID).
 		SetUsername(config.Username).
 		SetPassword(config.AccessCode).
-		SetTLSConfig(&tls.Config{InsecureSkipVerify: true}).
-		SetAutoReconnect(true)
+		SetTLSConfig(config.tlsConfig()).
+		SetAutoReconnect(false) // See reconnect
+	if config.Timeout > 0 {
+		opts.SetConnectTimeout(config.Timeout)
+	}
 
 	client := &Client{
-		config:      config,
-		data:        make(map[string]Message),
-		messageChan: make(chan paho.Message, 200),
-		doneChan:    make(chan struct{}),
-		ticker:      time.NewTicker(updateInterval),
+		config:   config,
+		data:     make(map[string]*report),
+		pushAll:  make(map[string]time.Time),
+		queues:   newQueues(),
+		doneChan: make(chan struct{}),
+		ticker:   time.NewTicker(updateInterval),
 	}
 
 	opts.SetOnConnectHandler(client.onConnect)
@@ -68,18 +103,28 @@ func NewClient(config *ClientConfig) *Client {
 }
 
 func (c *Client) Connect() error {
+	c.setState(Connecting)
 	token := c.client.Connect()
 	if token.Wait() && token.Error() != nil {
+		c.setState(Disconnected)
 		return fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
 	}
 	log.Println("Connected to MQTT broker")
 	go c.processMessages()
 	go c.periodicUpdate()
-	c.updateAllSerials()
 	return nil
 }
 
+// Disconnect disconnects from the broker and stops the client. Calling it again is
+// a no-op.
 func (c *Client) Disconnect() {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.closed() {
+		return
+	}
+	c.setState(Disconnected)
 	close(c.doneChan)
 	c.ticker.Stop()
 	c.client.Disconnect(250)
@@ -92,7 +137,11 @@ func (c *Client) Publish(command *Command) error {
 		return fmt.Errorf("failed to marshal command: %w", err)
 	}
 
-	topic := fmt.Sprintf(commandTopic, c.config.Serials[0])
+	serials := c.Serials()
+	if len(serials) == 0 {
+		return fmt.Errorf("no serial to publish to")
+	}
+	topic := fmt.Sprintf(commandTopic, serials[0])
 	token := c.client.Publish(topic, qos, false, rawCommand)
 	if token.Wait() && token.Error() != nil {
 		return fmt.Errorf("failed to publish to topic %s: %w", topic, token.Error())
@@ -103,84 +152,199 @@ func (c *Client) Publish(command *Command) error {
 }
 
 func (c *Client) Data(serial string) Message {
+	c.mutex.Lock()
+	r, ok := c.data[serial]
+	c.mutex.Unlock()
+
+	if !ok {
+		return Message{}
+	}
+	return r.snapshot()
+}
+
+// RawData returns the merged raw JSON document of all reports received from the
+// printer, including fields Message does not know about. It returns nil if no
+// report has been received yet.
+func (c *Client) RawData(serial string) json.RawMessage {
+	c.mutex.Lock()
+	r, ok := c.data[serial]
+	c.mutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return r.raw()
+}
+
+// LastSeen returns the time the last report of a printer was received, zero if none
+// has been received yet.
+func (c *Client) LastSeen(serial string) time.Time {
+	c.mutex.Lock()
+	r, ok := c.data[serial]
+	c.mutex.Unlock()
+
+	if !ok {
+		return time.Time{}
+	}
+	return r.lastSeen()
+}
+
+// Serials returns the serial numbers of the printers the client receives reports from.
+func (c *Client) Serials() []string {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	return c.data[serial]
+	return slices.Clone(c.config.Serials)
 }
 
-func (c *Client) onConnect(client paho.Client) {
-	for _, serial := range c.config.Serials {
-		topic := fmt.Sprintf(topicTemplate, serial)
-		token := client.Subscribe(topic, qos, nil)
-		if token.Wait() && token.Error() != nil {
-			log.Printf("Failed to subscribe to topic %s: %v", topic, token.Error())
-			return
-		}
-		log.Printf("Subscribed to topic %s", topic)
+// AddSerial starts receiving the reports of another printer. When the client is
+// connected, it subscribes to the printer's reports and requests a full report right
+// away; otherwise the subscription is made on the next connection.
+func (c *Client) AddSerial(serial string) error {
+	c.mutex.Lock()
+	if slices.Contains(c.config.Serials, serial) {
+		c.mutex.Unlock()
+		return nil
 	}
+	c.config.Serials = append(slices.Clone(c.config.Serials), serial)
+	c.mutex.Unlock()
+
+	if !c.client.IsConnectionOpen() {
+		return nil
+	}
+	if err := c.subscribe(c.client, serial); err != nil {
+		return err
+	}
+	return c.requestPushAll(serial)
+}
+
+// RemoveSerial stops receiving the reports of a printer and forgets its state.
+func (c *Client) RemoveSerial(serial string) error {
+	c.mutex.Lock()
+	i := slices.Index(c.config.Serials, serial)
+	if i < 0 {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.config.Serials = slices.Delete(slices.Clone(c.config.Serials), i, i+1)
+	delete(c.data, serial)
+	delete(c.pushAll, serial)
+	c.mutex.Unlock()
+
+	if !c.client.IsConnectionOpen() {
+		return nil
+	}
+	topic := fmt.Sprintf(topicTemplate, serial)
+	token := c.client.Unsubscribe(topic)
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("failed to unsubscribe from topic %s: %w", topic, token.Error())
+	}
+	log.Printf("Unsubscribed from topic %s", topic)
+	return nil
+}
+
+func (c *Client) subscribe(client paho.Client, serial string) error {
+	topic := fmt.Sprintf(topicTemplate, serial)
+	token := client.Subscribe(topic, qos, nil)
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("failed to subscribe to topic %s: %w", topic, token.Error())
+	}
+	log.Printf("Subscribed to topic %s", topic)
+	return nil
 }
 
-func (c *Client) onConnectionLost(client paho.Client, err error) {
-	log.Printf("Connection lost: %v", err)
+// queuedMessage is a report waiting to be ingested, along with the serial of the
+// printer it comes from.
+type queuedMessage struct {
+	serial  string
+	payload []byte
 }
 
 func (c *Client) handleMessage(client paho.Client, msg paho.Message) {
+	serial := extractSerialFromTopic(msg.Topic())
+	if serial == "" {
+		return
+	}
+	queue := c.queues[queueIndex(serial)]
+	message := queuedMessage{serial: serial, payload: msg.Payload()}
+
 	select {
-	case c.messageChan <- msg:
+	case queue <- message:
 		log.Printf("Message received: %s", msg.Topic())
 	default:
-		<-c.messageChan
-		c.messageChan <- msg
+		select {
+		case <-queue:
+		default:
+		}
+		queue <- message
 		log.Println("Message dropped: channel full")
 	}
 }
 
-const workerCount = 10
+const (
+	workerCount = 10
+	queueSize   = 32
+)
+
+// Reports of a given printer are always handled by the same worker so that they are
+// applied in the order they arrived, while different printers are decoded in parallel.
+func newQueues() []chan queuedMessage {
+	queues := make([]chan queuedMessage, workerCount)
+	for i := range queues {
+		queues[i] = make(chan queuedMessage, queueSize)
+	}
+	return queues
+}
+
+func queueIndex(serial string) int {
+	h := fnv.New32a()
+	h.Write([]byte(serial))
+	return int(h.Sum32() % workerCount)
+}
 
 func (c *Client) processMessages() {
 	var wg sync.WaitGroup
-	for i := 0; i < workerCount; i++ {
+	for _, queue := range c.queues {
 		wg.Add(1)
-		go func() {
+		go func(queue chan queuedMessage) {
 			defer wg.Done()
 			for {
 				select {
-				case msg := <-c.messageChan:
-					c.processPayload(msg)
+				case msg := <-queue:
+					c.ingest(msg.serial, msg.payload)
 				case <-c.doneChan:
 					return
 				}
 			}
-		}()
+		}(queue)
 	}
 	wg.Wait()
 }
 
-func (c *Client) processPayload(msg paho.Message) {
-	var received Message
-	if err := json.Unmarshal(msg.Payload(), &received); err != nil {
-		log.Printf("Failed to unmarshal message: %v", err)
-		return
-	}
-
-	serial := extractSerialFromTopic(msg.Topic())
-
+// ingest merges a report payload into the stored state of the given printer.
+func (c *Client) ingest(serial string, payload []byte) {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	r, ok := c.data[serial]
+	if !ok {
+		r = newReport()
+		c.data[serial] = r
+	}
+	c.mutex.Unlock()
 
-	if existing, exists := c.data[serial]; exists {
-		mergeMessages(&existing, &received)
-		c.data[serial] = existing
-	} else {
-		c.data[serial] = received
+	// Any report shows the printer is alive, even one that cannot be decoded.
+	r.touch(time.Now())
+	if err := r.apply(payload); err != nil {
+		log.Printf("Failed to decode message for %s: %v", serial, err)
 	}
+
+	c.resolve(serial, payload)
+	c.notifyFullReport(serial, payload)
 }
 
 func extractSerialFromTopic(topic string) string {
-	re := regexp.MustCompile(`device/([^/]+)/report`)
-	matches := re.FindStringSubmatch(topic)
-	if len(matches) > 1 {
-		return matches[1]
+	if rest, ok := strings.CutPrefix(topic, "device/"); ok {
+		if serial, ok := strings.CutSuffix(rest, "/report"); ok && serial != "" && !strings.Contains(serial, "/") {
+			return serial
+		}
 	}
 	log.Println("Failed to extract serial from topic:", topic)
 	return ""
@@ -188,54 +352,68 @@ func extractSerialFromTopic(topic string) string {
 
 // Private methods
 
-// update triggers a data refresh by publishing a "push_all" command.
-func (c *Client) update() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	if time.Since(c.lastUpdate) < c.config.Timeout {
-		return
-	}
-
-	c.lastUpdate = time.Now()
-	command := NewCommand(Pushing).AddCommandField("pushall")
-	js, _ := command.JSON()
-	fmt.Println("command", js)
-	if err := c.Publish(command); err != nil {
-		log.Printf("Failed to publish update command: %v", err)
-	}
-}
-
 func (c *Client) periodicUpdate() {
 	for {
 		select {
 		case <-c.ticker.C:
-			c.updateAllSerials()
+			c.refreshSerials()
 		case <-c.doneChan:
 			return
 		}
 	}
 }
 
-func (c *Client) updateAllSerials() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	if time.Since(c.lastUpdate) < c.config.Timeout {
+// refreshSerials requests a full report from the printers that need one, see
+// needsRefresh.
+func (c *Client) refreshSerials() {
+	if c.State() != Connected {
 		return
 	}
 
-	c.lastUpdate = time.Now()
-	for _, serial := range c.config.Serials {
-		command := NewCommand(Pushing).AddCommandField("pushall")
-		js, _ := command.JSON()
-		fmt.Println("command", js)
-		if err := c.PublishToSerial(command, serial); err != nil {
+	now := time.Now()
+	for _, serial := range c.Serials() {
+		if !c.needsRefresh(serial, now) {
+			continue
+		}
+		if err := c.requestPushAll(serial); err != nil {
 			log.Printf("Failed to publish update command to serial %s: %v", serial, err)
 		}
 	}
 }
 
+// needsRefresh reports whether a full report should be requested from a printer:
+// none was requested for the push-all interval, and the printer has sent no report
+// yet or none for the push-all interval.
+func (c *Client) needsRefresh(serial string, now time.Time) bool {
+	interval := c.pushAllInterval()
+
+	c.mutex.Lock()
+	requested := c.pushAll[serial]
+	r, ok := c.data[serial]
+	c.mutex.Unlock()
+
+	if !requested.IsZero() && now.Sub(requested) < interval {
+		return false
+	}
+	return !ok || now.Sub(r.lastSeen()) >= interval
+}
+
+func (c *Client) pushAllInterval() time.Duration {
+	if c.config.PushAllInterval > 0 {
+		return c.config.PushAllInterval
+	}
+	return DefaultPushAllInterval
+}
+
+// requestPushAll asks a printer for a full report.
+func (c *Client) requestPushAll(serial string) error {
+	c.mutex.Lock()
+	c.pushAll[serial] = time.Now()
+	c.mutex.Unlock()
+
+	return c.PublishToSerial(NewCommand(Pushing).AddCommandField("pushall"), serial)
+}
+
 func (c *Client) PublishToSerial(command *Command, serial string) error {
 	rawCommand, err := command.JSON()
 	if err != nil {
@@ -251,37 +429,3 @@ func (c *Client) PublishToSerial(command *Command, serial string) error {
 	log.Printf("Published command to topic %s", topic)
 	return nil
 }
-
-// mergeMessages recursively merges the existing and new messages.
-func mergeMessages(existing, new *Message) {
-	// Use reflection to iterate through the fields of the "Print" struct.
-	mergeStructs(&existing.Print, &new.Print)
-}
-
-// mergeStructs dynamically merges fields of two structs using reflection.
-func mergeStructs(existing, new interface{}) {
-	existingVal := reflect.ValueOf(existing).Elem()
-	newVal := reflect.ValueOf(new).Elem()
-
-	// Iterate over each field in the struct.
-	for i := 0; i < existingVal.NumField(); i++ {
-		field := existingVal.Field(i)
-
-		// Ensure that the field is a valid field to merge.
-		newField := newVal.Field(i)
-		if !newField.IsValid() {
-			continue
-		}
-
-		// Only merge if the field is non-zero in the new struct.
-		if !newField.IsZero() {
-			// If it's a struct, recursively merge it.
-			if newField.Kind() == reflect.Struct {
-				mergeStructs(field.Addr().Interface(), newField.Addr().Interface())
-			} else {
-				// Otherwise, set the field to the new value.
-				field.Set(newField)
-			}
-		}
-	}
-}
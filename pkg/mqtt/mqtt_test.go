@@ -0,0 +1,137 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Serials(t *testing.T) {
+	config := &ClientConfig{Serials: []string{"SERIAL"}}
+	client := NewClient(config)
+
+	// Without a connection, serials are only recorded for the next subscription.
+	require.NoError(t, client.AddSerial("OTHER"))
+	require.NoError(t, client.AddSerial("SERIAL"))
+	assert.Equal(t, []string{"SERIAL", "OTHER"}, client.Serials())
+
+	client.ingest("SERIAL", []byte(`{"print":{"gcode_state":"RUNNING"}}`))
+	require.NoError(t, client.RemoveSerial("SERIAL"))
+	require.NoError(t, client.RemoveSerial("MISSING"))
+	assert.Equal(t, []string{"OTHER"}, client.Serials())
+	assert.Empty(t, client.Data("SERIAL").Print.GcodeState, "reports of removed printers are forgotten")
+}
+
+func TestClient_LastSeen(t *testing.T) {
+	client := newTestClient()
+	assert.True(t, client.LastSeen("SERIAL").IsZero())
+
+	before := time.Now()
+	client.ingest("SERIAL", []byte(`{"print":{"gcode_state":"RUNNING"}}`))
+	assert.WithinRange(t, client.LastSeen("SERIAL"), before, time.Now())
+
+	// Reports that cannot be decoded still show the printer is alive.
+	seen := client.LastSeen("SERIAL")
+	time.Sleep(time.Millisecond)
+	client.ingest("SERIAL", []byte(`not json`))
+	assert.True(t, client.LastSeen("SERIAL").After(seen))
+}
+
+func TestClient_NeedsRefresh(t *testing.T) {
+	client := NewClient(&ClientConfig{PushAllInterval: time.Minute})
+	now := time.Now()
+
+	assert.True(t, client.needsRefresh("SERIAL", now), "no snapshot yet")
+
+	client.ingest("SERIAL", []byte(`{"print":{"gcode_state":"RUNNING"}}`))
+	now = time.Now()
+	assert.False(t, client.needsRefresh("SERIAL", now), "fresh snapshot")
+	assert.True(t, client.needsRefresh("SERIAL", now.Add(time.Minute)), "stale snapshot")
+
+	// A full report is requested at most once per interval, even without an answer.
+	client.pushAll["SERIAL"] = now
+	assert.False(t, client.needsRefresh("SERIAL", now.Add(30*time.Second)))
+	assert.True(t, client.needsRefresh("SERIAL", now.Add(2*time.Minute)))
+	assert.True(t, client.needsRefresh("OTHER", now), "tracked per serial")
+}
+
+func TestClient_PushAllInterval(t *testing.T) {
+	assert.Equal(t, DefaultPushAllInterval, NewClient(&ClientConfig{}).pushAllInterval())
+	assert.Equal(t, time.Hour, NewClient(&ClientConfig{PushAllInterval: time.Hour}).pushAllInterval())
+}
+
+func TestClient_ConnectTimeout(t *testing.T) {
+	connectTimeout := func(config *ClientConfig) time.Duration {
+		options := NewClient(config).client.OptionsReader()
+		return options.ConnectTimeout()
+	}
+	assert.Equal(t, 30*time.Second, connectTimeout(&ClientConfig{}))
+	assert.Equal(t, 10*time.Second, connectTimeout(&ClientConfig{Timeout: 10 * time.Second}))
+}
+
+func TestClient_AwaitFullReport(t *testing.T) {
+	client := newTestClient()
+
+	report, cancel := client.awaitFullReport("SERIAL")
+	defer cancel()
+	other, cancelOther := client.awaitFullReport("OTHER")
+	defer cancelOther()
+
+	client.ingest("SERIAL", []byte(`{"print":{"command":"push_status","msg":1,"nozzle_temper":200}}`))
+	client.ingest("SERIAL", []byte(`{"print":{"command":"push_status","nozzle_temper":200}}`))
+	select {
+	case <-report:
+		t.Fatal("deltas are not full reports")
+	default:
+	}
+
+	client.ingest("SERIAL", []byte(`{"print":{"command":"push_status","msg":0,"nozzle_temper":210}}`))
+	select {
+	case <-report:
+	default:
+		t.Fatal("full report not delivered")
+	}
+	select {
+	case <-other:
+		t.Fatal("full report delivered to another printer")
+	default:
+	}
+
+	cancelOther()
+	assert.Empty(t, client.fullReports)
+}
+
+func TestClient_RefreshNotConnected(t *testing.T) {
+	client := NewClient(&ClientConfig{})
+	assert.Error(t, client.Refresh(context.Background(), "SERIAL"))
+	assert.Empty(t, client.fullReports)
+}
+
+type fakeMessage struct {
+	paho.Message
+	topic   string
+	payload []byte
+}
+
+func (m fakeMessage) Topic() string   { return m.topic }
+func (m fakeMessage) Payload() []byte { return m.payload }
+
+func TestClient_HandleMessage(t *testing.T) {
+	client := NewClient(&ClientConfig{})
+
+	client.handleMessage(nil, fakeMessage{topic: "device/a/b/report", payload: []byte(`{}`)})
+	for _, queue := range client.queues {
+		assert.Empty(t, queue, "messages with an invalid topic are dropped")
+	}
+
+	client.handleMessage(nil, fakeMessage{topic: "device/SERIAL/report", payload: []byte(`{"print":{}}`)})
+	queue := client.queues[queueIndex("SERIAL")]
+	require.Len(t, queue, 1)
+	message := <-queue
+	assert.Equal(t, "SERIAL", message.serial)
+	assert.Equal(t, `{"print":{}}`, string(message.payload))
+}
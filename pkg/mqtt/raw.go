@@ -0,0 +1,269 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Alongside the typed Message, the raw JSON of every report is kept so that fields
+// the library does not know about yet are not lost. Merging it costs about as much
+// as decoding the report, so payloads are only queued when they arrive and merged
+// when the document is needed, see report.raw.
+//
+// The document is merged with the same rules as the typed state: objects key by
+// key, arrays of objects carrying an "id" element by element, everything else
+// replaced. As in mergeAmsTrays, an AMS tray reported with nothing but its id is
+// reset, while other elements reported that way keep their state. Values are kept as json.RawMessage and only split into their fields or
+// elements once a report needs to merge into them, so most of the document is
+// never decoded.
+
+// trayKey holds the trays of an AMS unit, the only elements reset when reported
+// with nothing but their id.
+const trayKey = "tray"
+
+// ErrPathNotFound is returned by Lookup when the path does not exist in the document.
+var ErrPathNotFound = errors.New("path not found")
+
+// rawNode is a value of the raw document: either its encoded form, or the fields of
+// an object or the elements of an array of objects once expanded.
+type rawNode struct {
+	raw      json.RawMessage
+	fields   map[string]*rawNode
+	elements []*rawNode
+	id       string          // Id of an element of an array of objects
+	last     json.RawMessage // Last value merged into the node
+}
+
+func newRawNode(raw json.RawMessage, id string) *rawNode {
+	return &rawNode{raw: raw, id: id, last: raw}
+}
+
+// merge merges an encoded value, found under key in its parent object, into the
+// node. Merging the same value twice is a no-op, so values repeated from one report
+// to the next, as most of a full report is, are skipped without being decoded.
+func (n *rawNode) merge(key string, patch json.RawMessage) {
+	if bytes.Equal(n.last, patch) {
+		return
+	}
+	n.last = patch
+
+	switch {
+	case isObject(patch) && n.isObject():
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(patch, &fields) != nil {
+			n.replace(patch)
+			return
+		}
+		n.mergeFields(fields)
+	case isArray(patch) && n.isArray():
+		n.mergeElements(patch, key == trayKey)
+	default:
+		n.replace(patch)
+	}
+}
+
+func (n *rawNode) mergeFields(fields map[string]json.RawMessage) {
+	if !n.expandObject() {
+		n.fields = make(map[string]*rawNode, len(fields))
+	}
+	for key, value := range fields {
+		if child, ok := n.fields[key]; ok {
+			child.merge(key, value)
+		} else {
+			n.fields[key] = newRawNode(value, "")
+		}
+	}
+}
+
+// mergeElements merges an array of objects by their "id". The patch decides which
+// elements exist and in which order. With resetIDOnly, an element holding nothing
+// but its id replaces the existing element instead of being merged into it. Any
+// other array replaces the node.
+func (n *rawNode) mergeElements(patch json.RawMessage, resetIDOnly bool) {
+	var elements []json.RawMessage
+	if json.Unmarshal(patch, &elements) != nil || len(elements) == 0 {
+		n.replace(patch)
+		return
+	}
+
+	type element struct {
+		id     string
+		fields map[string]json.RawMessage
+	}
+	patches := make([]element, len(elements))
+	for i, raw := range elements {
+		id, fields, ok := elementFields(raw)
+		if !ok {
+			n.replace(patch)
+			return
+		}
+		patches[i] = element{id, fields}
+	}
+
+	n.expandArray()
+	byID := make(map[string]*rawNode, len(n.elements))
+	for _, existing := range n.elements {
+		if existing.id != "" {
+			byID[existing.id] = existing
+		}
+	}
+
+	merged := make([]*rawNode, len(patches))
+	for i, p := range patches {
+		existing, ok := byID[p.id]
+		if !ok || resetIDOnly && len(p.fields) == 1 {
+			merged[i] = newRawNode(elements[i], p.id)
+			continue
+		}
+		if !bytes.Equal(existing.last, elements[i]) {
+			existing.last = elements[i]
+			existing.mergeFields(p.fields)
+		}
+		merged[i] = existing
+	}
+	n.elements = merged
+}
+
+func (n *rawNode) replace(raw json.RawMessage) {
+	n.raw, n.fields, n.elements = raw, nil, nil
+}
+
+func (n *rawNode) isObject() bool {
+	return n.fields != nil || isObject(n.raw)
+}
+
+func (n *rawNode) isArray() bool {
+	return n.elements != nil || isArray(n.raw)
+}
+
+// expandObject splits the encoded object into its fields, reporting whether the
+// node holds an object.
+func (n *rawNode) expandObject() bool {
+	if n.fields != nil {
+		return true
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(n.raw, &fields) != nil || fields == nil {
+		return false
+	}
+	n.fields = make(map[string]*rawNode, len(fields))
+	for key, value := range fields {
+		n.fields[key] = newRawNode(value, "")
+	}
+	n.raw = nil
+	return true
+}
+
+// expandArray splits the encoded array into its elements. Elements that are not
+// objects with an id are kept but never merged into.
+func (n *rawNode) expandArray() {
+	if n.elements != nil {
+		return
+	}
+	var elements []json.RawMessage
+	_ = json.Unmarshal(n.raw, &elements)
+	n.elements = make([]*rawNode, len(elements))
+	for i, raw := range elements {
+		id, _, _ := elementFields(raw)
+		n.elements[i] = newRawNode(raw, id)
+	}
+	n.raw = nil
+}
+
+// MarshalJSON encodes the node, reusing the encoded form of the values no report
+// merged into.
+func (n *rawNode) MarshalJSON() ([]byte, error) {
+	switch {
+	case n.fields != nil:
+		return json.Marshal(n.fields)
+	case n.elements != nil:
+		return json.Marshal(n.elements)
+	default:
+		return n.raw, nil
+	}
+}
+
+// elementFields decodes an element of an array, ok being false if it is not an
+// object with an id.
+func elementFields(raw json.RawMessage) (id string, fields map[string]json.RawMessage, ok bool) {
+	if !isObject(raw) || json.Unmarshal(raw, &fields) != nil {
+		return "", nil, false
+	}
+	value, ok := fields["id"]
+	if !ok {
+		return "", nil, false
+	}
+	// "0" and 0 designate the same element.
+	return string(bytes.Trim(value, `"`)), fields, true
+}
+
+func isObject(raw json.RawMessage) bool {
+	raw = bytes.TrimLeft(raw, " \t\r\n")
+	return len(raw) > 0 && raw[0] == '{'
+}
+
+func isArray(raw json.RawMessage) bool {
+	raw = bytes.TrimLeft(raw, " \t\r\n")
+	return len(raw) > 0 && raw[0] == '['
+}
+
+// Lookup decodes the value at path in a raw report document into T. The path is a
+// dot-separated list of object keys and array indexes, e.g.
+// "print.ams.ams.0.tray.1.remain".
+func Lookup[T any](raw json.RawMessage, path string) (T, error) {
+	var value T
+
+	current := raw
+	for _, segment := range strings.Split(path, ".") {
+		next, err := lookupSegment(current, segment)
+		if err != nil {
+			return value, fmt.Errorf("%s: %w", path, err)
+		}
+		current = next
+	}
+
+	if err := json.Unmarshal(current, &value); err != nil {
+		return value, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return value, nil
+}
+
+func lookupSegment(raw json.RawMessage, segment string) (json.RawMessage, error) {
+	raw = bytes.TrimLeft(raw, " \t\r\n")
+	if len(raw) == 0 {
+		return nil, ErrPathNotFound
+	}
+
+	switch raw[0] {
+	case '{':
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, err
+		}
+		value, ok := obj[segment]
+		if !ok {
+			return nil, ErrPathNotFound
+		}
+		return value, nil
+	case '[':
+		index, err := strconv.Atoi(segment)
+		if err != nil {
+			return nil, ErrPathNotFound
+		}
+		var array []json.RawMessage
+		if err := json.Unmarshal(raw, &array); err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= len(array) {
+			return nil, ErrPathNotFound
+		}
+		return array[index], nil
+	default:
+		return nil, ErrPathNotFound
+	}
+}
@@ -0,0 +1,157 @@
+package mqtt
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_RawDataKeepsUnknownFields(t *testing.T) {
+	client := newTestClient()
+	assert.Nil(t, client.RawData("SERIAL"))
+
+	client.ingest("SERIAL", []byte(fullReport))
+	client.ingest("SERIAL", []byte(`{"print":{"nozzle_type":"hardened_steel","fan_gear":0,"new_feature":{"enabled":true,"level":2}}}`))
+	client.ingest("SERIAL", []byte(`{"print":{"new_feature":{"level":3}},"info":{"command":"get_version"}}`))
+
+	raw := client.RawData("SERIAL")
+	require.NotNil(t, raw)
+
+	nozzle, err := Lookup[string](raw, "print.nozzle_type")
+	assert.NoError(t, err)
+	assert.Equal(t, "hardened_steel", nozzle)
+
+	enabled, err := Lookup[bool](raw, "print.new_feature.enabled")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	level, err := Lookup[int](raw, "print.new_feature.level")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, level)
+
+	command, err := Lookup[string](raw, "info.command")
+	assert.NoError(t, err)
+	assert.Equal(t, "get_version", command)
+
+	temp, err := Lookup[float64](raw, "print.bed_temper")
+	assert.NoError(t, err)
+	assert.Equal(t, 60.5, temp)
+}
+
+func TestClient_RawDataMergesTraysByID(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(fullReport))
+	client.ingest("SERIAL", []byte(`{"print":{"ams":{"ams":[{"id":"0","tray":[{"id":"0","remain":70},{"id":"1"},{"id":"2"},{"id":"3"}]}]}}}`))
+
+	raw := client.RawData("SERIAL")
+
+	remain, err := Lookup[int](raw, "print.ams.ams.0.tray.0.remain")
+	assert.NoError(t, err)
+	assert.Equal(t, 70, remain)
+
+	trayType, err := Lookup[string](raw, "print.ams.ams.0.tray.0.tray_type")
+	assert.NoError(t, err)
+	assert.Equal(t, "PLA", trayType)
+
+	_, err = Lookup[string](raw, "print.ams.ams.0.tray.1.tray_type")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+
+	humidity, err := Lookup[string](raw, "print.ams.ams.0.humidity")
+	assert.NoError(t, err)
+	assert.Equal(t, "4", humidity)
+}
+
+func TestClient_RawDataSecondAmsUnitAttached(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(fullReport))
+	client.ingest("SERIAL", []byte(`{"print":{"command":"push_status","sequence_id":"2","ams":{"ams_exist_bits":"3",
+		"ams":[{"id":"0"},{"id":"1","humidity":"5","temp":"22.0","tray":[{"id":"0","tray_type":"TPU"}]}]}}}`))
+
+	raw := client.RawData("SERIAL")
+
+	trayType, err := Lookup[string](raw, "print.ams.ams.1.tray.0.tray_type")
+	assert.NoError(t, err)
+	assert.Equal(t, "TPU", trayType)
+
+	// A unit reported with nothing but its id keeps its state, as in Data.
+	humidity, err := Lookup[string](raw, "print.ams.ams.0.humidity")
+	assert.NoError(t, err)
+	assert.Equal(t, "4", humidity)
+	assert.Equal(t, client.Data("SERIAL").Print.Ams.Ams[0].Humidity, humidity)
+
+	trayType, err = Lookup[string](raw, "print.ams.ams.0.tray.0.tray_type")
+	assert.NoError(t, err)
+	assert.Equal(t, "PLA", trayType)
+}
+
+func TestLookup(t *testing.T) {
+	raw := []byte(`{"a":{"b":[{"c":1},{"c":2}]},"s":"x"}`)
+
+	c, err := Lookup[int](raw, "a.b.1.c")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, c)
+
+	_, err = Lookup[int](raw, "a.b.2.c")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+
+	_, err = Lookup[int](raw, "a.b.x")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+
+	_, err = Lookup[int](raw, "s.t")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+
+	_, err = Lookup[int](raw, "s")
+	assert.Error(t, err)
+
+	_, err = Lookup[int](nil, "a")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+}
+
+func TestClient_RawDataPendingReports(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(fullReport))
+	for i := range maxPendingReports + 10 {
+		client.ingest("SERIAL", fmt.Appendf(nil, `{"print":{"mc_percent":%d}}`, i))
+	}
+
+	// Reports are merged once enough of them are queued, without waiting for a read.
+	client.mutex.Lock()
+	pending := len(client.data["SERIAL"].pending)
+	client.mutex.Unlock()
+	assert.Less(t, pending, maxPendingReports)
+
+	percent, err := Lookup[int](client.RawData("SERIAL"), "print.mc_percent")
+	assert.NoError(t, err)
+	assert.Equal(t, maxPendingReports+9, percent)
+}
+
+func TestClient_RawDataRepeatedReport(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(fullReport))
+
+	seventy := []byte(`{"print":{"ams":{"ams":[{"id":"0","tray":[{"id":"0","remain":70}]}]}}}`)
+	client.ingest("SERIAL", seventy)
+	client.RawData("SERIAL")
+	client.ingest("SERIAL", []byte(`{"print":{"ams":{"ams":[{"id":"0","tray":[{"id":"0","remain":50}]}]}}}`))
+	client.ingest("SERIAL", seventy)
+
+	remain, err := Lookup[int](client.RawData("SERIAL"), "print.ams.ams.0.tray.0.remain")
+	assert.NoError(t, err)
+	assert.Equal(t, 70, remain)
+}
+
+func TestClient_RawDataTypeMismatch(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(`{"print":{"gcode_state":"RUNNING","mc_percent":10}}`))
+	client.ingest("SERIAL", []byte(`{"print":{"gcode_state":"PAUSE","mc_percent":"soon"}}`))
+
+	// The typed state takes what it can decode, the raw document keeps everything.
+	assert.Equal(t, "PAUSE", client.Data("SERIAL").Print.GcodeState)
+	assert.Equal(t, 10, client.Data("SERIAL").Print.McPercent)
+
+	percent, err := Lookup[string](client.RawData("SERIAL"), "print.mc_percent")
+	assert.NoError(t, err)
+	assert.Equal(t, "soon", percent)
+}
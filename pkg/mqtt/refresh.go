@@ -0,0 +1,87 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Printers send a full push_status report, flagged with "msg": 0, when asked with a
+// pushall command and deltas holding only the values that changed otherwise.
+
+// Refresh requests a full report from the printer and waits until it is received,
+// regardless of the push-all interval.
+func (c *Client) Refresh(ctx context.Context, serial string) error {
+	report, cancel := c.awaitFullReport(serial)
+	defer cancel()
+
+	if err := c.requestPushAll(serial); err != nil {
+		return err
+	}
+
+	select {
+	case <-report:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for full report: %w", ctx.Err())
+	}
+}
+
+// awaitFullReport returns a channel closed when the next full report of the printer
+// is received, along with a function removing the waiter.
+func (c *Client) awaitFullReport(serial string) (<-chan struct{}, func()) {
+	report := make(chan struct{})
+
+	c.waitersMu.Lock()
+	if c.fullReports == nil {
+		c.fullReports = make(map[string][]chan struct{})
+	}
+	c.fullReports[serial] = append(c.fullReports[serial], report)
+	c.waitersMu.Unlock()
+
+	return report, func() {
+		c.waitersMu.Lock()
+		defer c.waitersMu.Unlock()
+		for i, waiter := range c.fullReports[serial] {
+			if waiter == report {
+				c.fullReports[serial] = append(c.fullReports[serial][:i:i], c.fullReports[serial][i+1:]...)
+				break
+			}
+		}
+		if len(c.fullReports[serial]) == 0 {
+			delete(c.fullReports, serial)
+		}
+	}
+}
+
+// notifyFullReport wakes the waiters of the printer if the payload is a full report.
+func (c *Client) notifyFullReport(serial string, payload []byte) {
+	c.waitersMu.Lock()
+	pending := len(c.fullReports[serial])
+	c.waitersMu.Unlock()
+	if pending == 0 || !isFullReport(payload) {
+		return
+	}
+
+	c.waitersMu.Lock()
+	waiters := c.fullReports[serial]
+	delete(c.fullReports, serial)
+	c.waitersMu.Unlock()
+
+	for _, waiter := range waiters {
+		close(waiter)
+	}
+}
+
+func isFullReport(payload []byte) bool {
+	var report struct {
+		Print struct {
+			Command string `json:"command"`
+			Msg     *int   `json:"msg"`
+		} `json:"print"`
+	}
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return false
+	}
+	return report.Print.Command == "push_status" && report.Print.Msg != nil && *report.Print.Msg == 0
+}
@@ -0,0 +1,99 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// maxPendingReports is the number of payloads queued for the raw document before
+// they are merged, bounding the memory kept for printers whose raw data is never
+// read.
+const maxPendingReports = 256
+
+// report holds the merged state of a single printer, both typed and as raw JSON.
+type report struct {
+	mu       sync.Mutex
+	message  Message
+	document *rawNode  // Raw document, nil until a payload was merged
+	pending  [][]byte  // Payloads not merged into document yet, oldest first
+	received time.Time // Time the last report was received
+}
+
+func newReport() *report {
+	return &report{}
+}
+
+// apply merges a report payload into the stored state. Fields whose type does not
+// match keep their previous value without preventing the rest of the payload from
+// being applied; outside the AMS units, which ignore them, they are reported in
+// the returned error. The payload is then queued
+// for the raw document, so it must not be modified afterwards.
+func (r *report) apply(payload []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message := r.message
+	message.detach()
+
+	err := json.Unmarshal(payload, &message)
+	if !isTypeError(err) {
+		return err
+	}
+
+	r.message = message
+	r.pending = append(r.pending, payload)
+	if len(r.pending) >= maxPendingReports {
+		r.flush()
+	}
+	return err
+}
+
+// flush merges the pending payloads into the raw document.
+func (r *report) flush() {
+	for _, payload := range r.pending {
+		if r.document == nil {
+			r.document = newRawNode(payload, "")
+		} else {
+			r.document.merge("", payload)
+		}
+	}
+	r.pending = nil
+}
+
+// touch records that a report was received at t.
+func (r *report) touch(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = t
+}
+
+// lastSeen returns the time the last report was received.
+func (r *report) lastSeen() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.received
+}
+
+// snapshot returns the typed state of the printer.
+func (r *report) snapshot() Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.message
+}
+
+// raw returns the merged raw JSON document of the printer.
+func (r *report) raw() json.RawMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.flush()
+	if r.document == nil {
+		return nil
+	}
+	raw, err := json.Marshal(r.document)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
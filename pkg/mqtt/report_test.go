@@ -0,0 +1,44 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractSerialFromTopic(t *testing.T) {
+	assert.Equal(t, "01P00A000000000", extractSerialFromTopic("device/01P00A000000000/report"))
+	assert.Equal(t, "", extractSerialFromTopic("device/01P00A000000000/request"))
+	assert.Equal(t, "", extractSerialFromTopic("device//report"))
+	assert.Equal(t, "", extractSerialFromTopic("device/a/b/report"))
+	assert.Equal(t, "", extractSerialFromTopic("other"))
+}
+
+func TestReport_SnapshotsAreIndependent(t *testing.T) {
+	r := newReport()
+	assert.NoError(t, r.apply([]byte(fullReport)))
+	before := r.snapshot()
+
+	assert.NoError(t, r.apply([]byte(`{"print":{"lights_report":[{"node":"chamber_light","mode":"off"}],
+		"ams":{"ams":[{"id":"0","tray":[{"id":"0","cols":["000000FF"]}]}]}}}`)))
+
+	assert.Equal(t, "on", before.Print.LightsReport[0].Mode)
+	assert.Equal(t, []string{"FFFFFFFF"}, before.Print.Ams.Ams[0].Tray[0].Cols)
+	assert.Len(t, before.Print.Ams.Ams[0].Tray, 4)
+	assert.Equal(t, "off", r.snapshot().Print.LightsReport[0].Mode)
+	assert.Equal(t, []string{"000000FF"}, r.snapshot().Print.Ams.Ams[0].Tray[0].Cols)
+}
+
+func TestReport_TypeMismatchInTray(t *testing.T) {
+	r := newReport()
+	assert.NoError(t, r.apply([]byte(fullReport)))
+
+	err := r.apply([]byte(`{"print":{"ams":{"ams":[{"id":"0","tray":[{"id":"0","remain":"x"},{"id":"1","remain":40}]}]},"mc_percent":50}}`))
+	assert.NoError(t, err)
+
+	trays := r.snapshot().Print.Ams.Ams[0].Tray
+	assert.Equal(t, 50, r.snapshot().Print.McPercent)
+	assert.Equal(t, "PLA", trays[0].TrayType)
+	assert.Equal(t, 80, trays[0].Remain)
+	assert.Equal(t, 40, trays[1].Remain)
+}
@@ -0,0 +1,127 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Commands are answered on the report topic with an object of the same type
+// ("print", "info", "system"...) carrying the command name and sequence_id of the
+// command. Request publishes a command with a unique sequence_id and waits for that
+// reply. The command name is matched as well since periodic push_status reports
+// carry sequence ids of their own.
+
+type waiterKey struct {
+	serial     string
+	section    MessageType
+	command    string
+	sequenceID string
+}
+
+// Request publishes a command to the printer and waits for its reply. It returns the
+// raw reply object, or an error if the printer reports that the command failed.
+func (c *Client) Request(ctx context.Context, serial string, command *Command) (json.RawMessage, error) {
+	sequenceID := strconv.FormatUint(c.sequence.Add(1), 10)
+	command.AddIdField(sequenceID)
+
+	name, _ := command.fields["command"].(string)
+	reply, cancel := c.register(waiterKey{serial: serial, section: command.Type, command: name, sequenceID: sequenceID})
+	defer cancel()
+
+	if err := c.PublishToSerial(command, serial); err != nil {
+		return nil, err
+	}
+
+	select {
+	case raw := <-reply:
+		return raw, replyError(raw)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for reply to %s command: %w", command.Type, ctx.Err())
+	}
+}
+
+// register adds a waiter for a reply and returns the channel it is delivered on,
+// along with a function removing the waiter.
+func (c *Client) register(key waiterKey) (<-chan json.RawMessage, func()) {
+	reply := make(chan json.RawMessage, 1)
+
+	c.waitersMu.Lock()
+	if c.waiters == nil {
+		c.waiters = make(map[waiterKey]chan json.RawMessage)
+	}
+	c.waiters[key] = reply
+	c.waitersMu.Unlock()
+
+	return reply, func() {
+		c.waitersMu.Lock()
+		delete(c.waiters, key)
+		c.waitersMu.Unlock()
+	}
+}
+
+// resolve delivers the objects of a report to the waiters expecting them.
+func (c *Client) resolve(serial string, payload []byte) {
+	c.waitersMu.Lock()
+	pending := len(c.waiters)
+	c.waitersMu.Unlock()
+	if pending == 0 {
+		return
+	}
+
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &root); err != nil {
+		return
+	}
+
+	for section, raw := range root {
+		var header struct {
+			Command    string          `json:"command"`
+			SequenceID json.RawMessage `json:"sequence_id"`
+		}
+		if err := json.Unmarshal(raw, &header); err != nil || header.SequenceID == nil {
+			continue
+		}
+
+		key := waiterKey{
+			serial:     serial,
+			section:    MessageType(section),
+			command:    header.Command,
+			sequenceID: string(bytes.Trim(header.SequenceID, `"`)),
+		}
+
+		c.waitersMu.Lock()
+		reply, ok := c.waiters[key]
+		if ok {
+			delete(c.waiters, key)
+		}
+		c.waitersMu.Unlock()
+
+		if ok {
+			reply <- raw
+		}
+	}
+}
+
+// replyError returns an error if a reply reports that its command failed.
+func replyError(raw json.RawMessage) error {
+	var reply struct {
+		Result string `json:"result"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return nil
+	}
+
+	switch reply.Result {
+	case "fail", "failed", "FAIL", "FAILED":
+		if reply.Reason == "" {
+			return fmt.Errorf("command failed")
+		}
+		return fmt.Errorf("command failed: %s", reply.Reason)
+	default:
+		return nil
+	}
+}
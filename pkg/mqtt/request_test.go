@@ -0,0 +1,59 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const versionReply = `{"info":{"command":"get_version","sequence_id":"7","result":"success","reason":"","module":[
+	{"name":"ota","project_name":"C11","sw_ver":"01.07.00.00","hw_ver":"","sn":"01P00A000000000"},
+	{"name":"ams/0","project_name":"","sw_ver":"00.00.06.49","hw_ver":"AMS08","sn":"00600A000000000"}
+]}}`
+
+func TestClient_ResolveReply(t *testing.T) {
+	client := newTestClient()
+
+	reply, cancel := client.register(waiterKey{serial: "SERIAL", section: Info, command: "get_version", sequenceID: "7"})
+	defer cancel()
+
+	client.ingest("SERIAL", []byte(`{"print":{"command":"push_status","sequence_id":"7"}}`))
+	client.ingest("OTHER", []byte(versionReply))
+	assert.Empty(t, reply)
+
+	client.ingest("SERIAL", []byte(versionReply))
+	require.Len(t, reply, 1)
+	assert.NoError(t, replyError(<-reply))
+
+	info := client.Data("SERIAL").Info
+	require.Len(t, info.Module, 2)
+	assert.Equal(t, "AMS08", info.Module[1].HwVer)
+}
+
+func TestClient_InfoModulesReplaced(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(versionReply))
+	client.ingest("SERIAL", []byte(`{"info":{"command":"get_version","sequence_id":"8","module":[{"name":"ota","sw_ver":"01.08.00.00"}]}}`))
+
+	info := client.Data("SERIAL").Info
+	require.Len(t, info.Module, 1)
+	assert.Equal(t, "01.08.00.00", info.Module[0].SwVer)
+	assert.Equal(t, "", info.Module[0].Sn)
+}
+
+func TestClient_SystemReply(t *testing.T) {
+	client := newTestClient()
+	client.ingest("SERIAL", []byte(`{"system":{"command":"ledctrl","led_node":"chamber_light","led_mode":"on","sequence_id":"3","result":"success","reason":""}}`))
+
+	system := client.Data("SERIAL").System
+	assert.Equal(t, "ledctrl", system.Command)
+	assert.Equal(t, "chamber_light", system.LedNode)
+	assert.Equal(t, "success", system.Result)
+}
+
+func TestReplyError(t *testing.T) {
+	assert.NoError(t, replyError([]byte(`{"result":"success"}`)))
+	assert.NoError(t, replyError([]byte(`{"command":"pause"}`)))
+	assert.EqualError(t, replyError([]byte(`{"result":"fail","reason":"busy"}`)), "command failed: busy")
+}
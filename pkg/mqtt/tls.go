@@ -0,0 +1,160 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"embed"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Certificates of the cloud brokers are verified like any other TLS server
+// certificate, against the system roots and the anchors bundled in certs/.
+//
+// The brokers running on the printers present a certificate signed by Bambu Lab's
+// own CA and issued to the printer's serial number rather than to a host name, as
+// printers are addressed by IP on the LAN. For these, the chain is verified against
+// the bundled CA and the certificate's common name is checked against the serial.
+
+//go:embed certs
+var certsFS embed.FS
+
+var (
+	bundledRootsOnce sync.Once
+	bundledRoots     []*x509.Certificate
+)
+
+// ErrCertificateSerial is returned when a printer presents a certificate issued to
+// another serial number than the one the client was configured for.
+var ErrCertificateSerial = errors.New("printer certificate issued to another serial")
+
+// BambuRootCAs returns a pool holding the trust anchors bundled with this package,
+// the CA signing the printers' certificates and the anchors of the cloud brokers.
+func BambuRootCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range loadBundledRoots() {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+func loadBundledRoots() []*x509.Certificate {
+	bundledRootsOnce.Do(func() {
+		entries, err := certsFS.ReadDir("certs")
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+				continue
+			}
+			data, err := certsFS.ReadFile(path.Join("certs", entry.Name()))
+			if err != nil {
+				continue
+			}
+			bundledRoots = append(bundledRoots, parseCertificates(data)...)
+		}
+	})
+	return bundledRoots
+}
+
+// parseCertificates returns the certificates of a PEM bundle, skipping anything
+// that does not parse.
+func parseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// tlsConfig returns the TLS configuration used to connect to the broker.
+func (c *ClientConfig) tlsConfig() *tls.Config {
+	if c.TLSConfig != nil {
+		return c.TLSConfig.Clone()
+	}
+	if c.InsecureSkipVerify {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+
+	if c.ServerSerial != "" {
+		roots := c.RootCAs
+		if roots == nil {
+			roots = BambuRootCAs()
+		}
+		return &tls.Config{
+			// Host name verification cannot succeed for printers, the chain and the
+			// serial are verified by VerifyConnection instead.
+			InsecureSkipVerify: true,
+			VerifyConnection:   verifyPrinterCertificate(roots, c.ServerSerial),
+		}
+	}
+
+	roots := c.RootCAs
+	if roots == nil {
+		roots = cloudRootCAs()
+	}
+	return &tls.Config{RootCAs: roots}
+}
+
+// cloudRootCAs returns the system roots extended with the bundled anchors.
+func cloudRootCAs() *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, cert := range loadBundledRoots() {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+// verifyPrinterCertificate verifies the certificate chain presented by a printer
+// against roots and checks that the certificate was issued to serial.
+func verifyPrinterCertificate(roots *x509.CertPool, serial string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("printer presented no certificate")
+		}
+
+		leaf := state.PeerCertificates[0]
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to verify printer certificate: %w", err)
+		}
+
+		if leaf.Subject.CommonName != serial {
+			return fmt.Errorf("%w: got %q, want %q", ErrCertificateSerial, leaf.Subject.CommonName, serial)
+		}
+		return nil
+	}
+}
+
+// TLSConfig returns the TLS configuration used to connect to the broker. Printers
+// present the same certificate on their other services, so it applies to them too.
+func (c *Client) TLSConfig() *tls.Config {
+	return c.config.tlsConfig()
+}
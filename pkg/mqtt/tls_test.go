@@ -0,0 +1,176 @@
+package mqtt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues certificates the way the printers' CA does, to the serial number
+// rather than to a host name.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "BBL CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca *testCA) issue(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// handshake runs a TLS handshake between a server presenting cert and a client
+// using config, connecting by IP as with printers on the LAN.
+func handshake(t *testing.T, cert tls.Certificate, config *tls.Config) error {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), config)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestClientConfig_TLSPrinter(t *testing.T) {
+	ca := newTestCA(t)
+
+	tests := []struct {
+		name    string
+		config  ClientConfig
+		cert    tls.Certificate
+		wantErr error
+	}{
+		{
+			name:   "serial matches",
+			config: ClientConfig{RootCAs: ca.pool(), ServerSerial: "01P00A000000000"},
+			cert:   ca.issue(t, "01P00A000000000"),
+		},
+		{
+			name:    "other serial",
+			config:  ClientConfig{RootCAs: ca.pool(), ServerSerial: "01P00A000000000"},
+			cert:    ca.issue(t, "01P00A999999999"),
+			wantErr: ErrCertificateSerial,
+		},
+		{
+			name:   "insecure",
+			config: ClientConfig{InsecureSkipVerify: true, ServerSerial: "01P00A000000000"},
+			cert:   newTestCA(t).issue(t, "anything"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := handshake(t, tt.cert, tt.config.tlsConfig())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestClientConfig_TLSPrinterUnknownCA(t *testing.T) {
+	config := ClientConfig{RootCAs: newTestCA(t).pool(), ServerSerial: "01P00A000000000"}
+	err := handshake(t, newTestCA(t).issue(t, "01P00A000000000"), config.tlsConfig())
+
+	var unknown x509.UnknownAuthorityError
+	assert.ErrorAs(t, err, &unknown)
+}
+
+func TestClientConfig_TLSCloudVerifiesByDefault(t *testing.T) {
+	config := ClientConfig{Host: "us.mqtt.bambulab.com"}
+	tlsConfig := config.tlsConfig()
+
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Error(t, handshake(t, newTestCA(t).issue(t, "us.mqtt.bambulab.com"), tlsConfig))
+}
+
+func TestClientConfig_TLSConfigOverride(t *testing.T) {
+	custom := &tls.Config{MinVersion: tls.VersionTLS13}
+	config := ClientConfig{TLSConfig: custom, InsecureSkipVerify: true}
+
+	tlsConfig := config.tlsConfig()
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+	assert.NotSame(t, custom, tlsConfig)
+}
+
+func TestBambuRootCAs(t *testing.T) {
+	roots := loadBundledRoots()
+	require.NotEmpty(t, roots)
+
+	assert.False(t, BambuRootCAs().Equal(x509.NewCertPool()))
+	for _, root := range roots {
+		assert.True(t, root.IsCA, "%s is not a CA", root.Subject)
+		assert.NoError(t, root.CheckSignatureFrom(root), "%s is not self-signed", root.Subject)
+	}
+
+	names := make([]string, len(roots))
+	for i, root := range roots {
+		names[i] = root.Subject.CommonName
+	}
+	assert.Contains(t, names, "BBL CA")
+}
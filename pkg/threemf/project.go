@@ -0,0 +1,362 @@
+package threemf
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The project is described by three files in the Metadata directory:
+//   - model_settings.config lists the plates and the objects placed on them,
+//   - slice_info.config holds the result of slicing each plate: estimated time,
+//     weight and the filaments it uses,
+//   - project_settings.config holds the slicer settings, including the type,
+//     colour and temperatures of each filament of the project.
+// Only model_settings.config is required; unsliced projects have no slice info.
+
+const (
+	modelSettingsFile   = "Metadata/model_settings.config"
+	sliceInfoFile       = "Metadata/slice_info.config"
+	projectSettingsFile = "Metadata/project_settings.config"
+)
+
+type Project struct {
+	Plates        []Plate    `json:"plates"`         // Plates of the project, by index
+	Filaments     []Filament `json:"filaments"`      // Filaments of the project, by ID
+	ClientVersion string     `json:"client_version"` // Version of the slicer that wrote the project
+}
+
+type Plate struct {
+	Index          int             `json:"index"`            // Number of the plate, from 1
+	Name           string          `json:"name"`             // Name given to the plate, if any
+	Sliced         bool            `json:"sliced"`           // Whether the plate has been sliced
+	GcodeFile      string          `json:"gcode_file"`       // Path of the sliced G-code in the archive
+	ThumbnailFile  string          `json:"thumbnail_file"`   // Path of the plate thumbnail in the archive
+	PrinterModelID string          `json:"printer_model_id"` // Model the plate was sliced for (C12, N2S, BL-P001...)
+	NozzleDiameter float64         `json:"nozzle_diameter"`  // Nozzle diameter the plate was sliced for (mm)
+	BedType        string          `json:"bed_type"`         // Build plate type the plate was sliced for
+	Prediction     time.Duration   `json:"prediction"`       // Estimated print time
+	Weight         float64         `json:"weight"`           // Estimated weight of the print (grams)
+	Filaments      []FilamentUsage `json:"filaments"`        // Filaments used by the plate
+	Objects        []Object        `json:"objects"`          // Objects printed on the plate
+}
+
+// Filament is a filament of the project. Plates refer to it by ID.
+type Filament struct {
+	ID            int        `json:"id"`              // Position of the filament in the project, from 1
+	Type          string     `json:"type"`            // Filament type (PLA, PETG, ABS...)
+	Color         color.RGBA `json:"color"`           // Filament colour
+	SettingID     string     `json:"setting_id"`      // Name of the filament preset
+	NozzleTempMin float64    `json:"nozzle_temp_min"` // Minimum nozzle temperature (°C)
+	NozzleTempMax float64    `json:"nozzle_temp_max"` // Maximum nozzle temperature (°C)
+}
+
+// FilamentUsage is the amount of a filament a plate uses.
+type FilamentUsage struct {
+	ID          int        `json:"id"`            // ID of the filament in the project
+	Type        string     `json:"type"`          // Filament type
+	Color       color.RGBA `json:"color"`         // Filament colour
+	TrayInfoIdx string     `json:"tray_info_idx"` // Bambu filament code (GFA00...)
+	UsedMeters  float64    `json:"used_meters"`   // Length of filament used (m)
+	UsedGrams   float64    `json:"used_grams"`    // Weight of filament used (grams)
+}
+
+// metadata is the key/value element used throughout the .config files.
+type metadata struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type metadataList []metadata
+
+func (m metadataList) get(key string) string {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return ""
+}
+
+type modelSettings struct {
+	Objects []struct {
+		ID       int          `xml:"id,attr"`
+		Metadata metadataList `xml:"metadata"`
+	} `xml:"object"`
+	Plates []struct {
+		Metadata  metadataList `xml:"metadata"`
+		Instances []struct {
+			Metadata metadataList `xml:"metadata"`
+		} `xml:"model_instance"`
+	} `xml:"plate"`
+}
+
+type sliceInfo struct {
+	Header struct {
+		Items []metadata `xml:"header_item"`
+	} `xml:"header"`
+	Plates []struct {
+		Metadata metadataList `xml:"metadata"`
+		Objects  []struct {
+			IdentifyID int    `xml:"identify_id,attr"`
+			Name       string `xml:"name,attr"`
+		} `xml:"object"`
+		Filaments []struct {
+			ID          int     `xml:"id,attr"`
+			TrayInfoIdx string  `xml:"tray_info_idx,attr"`
+			Type        string  `xml:"type,attr"`
+			Color       string  `xml:"color,attr"`
+			UsedMeters  float64 `xml:"used_m,attr"`
+			UsedGrams   float64 `xml:"used_g,attr"`
+		} `xml:"filament"`
+	} `xml:"plate"`
+}
+
+type projectSettings struct {
+	FilamentColour   []string `json:"filament_colour"`
+	FilamentType     []string `json:"filament_type"`
+	FilamentSettings []string `json:"filament_settings_id"`
+	NozzleTempLow    []string `json:"nozzle_temperature_range_low"`
+	NozzleTempHigh   []string `json:"nozzle_temperature_range_high"`
+}
+
+// Project reads the plates and filaments of the project.
+func (f *File) Project() (*Project, error) {
+	var settings modelSettings
+	if err := f.readXML(modelSettingsFile, &settings); err != nil {
+		return nil, err
+	}
+
+	var info sliceInfo
+	if err := f.readXML(sliceInfoFile, &info); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	project := &Project{}
+	for _, item := range info.Header.Items {
+		if item.Key == "X-BBL-Client-Version" {
+			project.ClientVersion = item.Value
+		}
+	}
+
+	filaments, err := f.projectFilaments()
+	if err != nil {
+		return nil, err
+	}
+	project.Filaments = filaments
+
+	objectNames := make(map[int]string)
+	for _, object := range settings.Objects {
+		objectNames[object.ID] = object.Metadata.get("name")
+	}
+
+	for _, p := range settings.Plates {
+		plate := Plate{
+			Index:         atoi(p.Metadata.get("plater_id")),
+			Name:          p.Metadata.get("plater_name"),
+			GcodeFile:     p.Metadata.get("gcode_file"),
+			ThumbnailFile: p.Metadata.get("thumbnail_file"),
+		}
+		for _, instance := range p.Instances {
+			plate.Objects = append(plate.Objects, Object{
+				ID:   atoi(instance.Metadata.get("identify_id")),
+				Name: objectNames[atoi(instance.Metadata.get("object_id"))],
+			})
+		}
+		project.Plates = append(project.Plates, plate)
+	}
+
+	for _, sliced := range info.Plates {
+		index := atoi(sliced.Metadata.get("index"))
+		plate := project.plate(index)
+
+		plate.Sliced = true
+		plate.PrinterModelID = sliced.Metadata.get("printer_model_id")
+		plate.NozzleDiameter = atof(sliced.Metadata.get("nozzle_diameters"))
+		plate.Prediction = time.Duration(atoi(sliced.Metadata.get("prediction"))) * time.Second
+		plate.Weight = atof(sliced.Metadata.get("weight"))
+
+		for _, filament := range sliced.Filaments {
+			c, _ := parseColor(filament.Color)
+			plate.Filaments = append(plate.Filaments, FilamentUsage{
+				ID:          filament.ID,
+				Type:        filament.Type,
+				Color:       c,
+				TrayInfoIdx: filament.TrayInfoIdx,
+				UsedMeters:  filament.UsedMeters,
+				UsedGrams:   filament.UsedGrams,
+			})
+		}
+
+		if len(plate.Objects) == 0 {
+			for _, object := range sliced.Objects {
+				plate.Objects = append(plate.Objects, Object{ID: object.IdentifyID, Name: object.Name})
+			}
+		}
+	}
+
+	for i := range project.Plates {
+		if err := f.addPlateMetadata(&project.Plates[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(project.Plates, func(i, j int) bool { return project.Plates[i].Index < project.Plates[j].Index })
+	return project, nil
+}
+
+// plate returns the plate with the given index, adding it if the model settings do
+// not list it.
+func (p *Project) plate(index int) *Plate {
+	for i := range p.Plates {
+		if p.Plates[i].Index == index {
+			return &p.Plates[i]
+		}
+	}
+	p.Plates = append(p.Plates, Plate{Index: index})
+	return &p.Plates[len(p.Plates)-1]
+}
+
+// Plate returns the plate with the given index, or nil if there is none.
+func (p *Project) Plate(index int) *Plate {
+	for i := range p.Plates {
+		if p.Plates[i].Index == index {
+			return &p.Plates[i]
+		}
+	}
+	return nil
+}
+
+// PlateFilaments returns the project filaments used by a plate, in ID order. When the
+// project settings are missing, the type and colour come from the slice info.
+func (p *Project) PlateFilaments(index int) []Filament {
+	plate := p.Plate(index)
+	if plate == nil {
+		return nil
+	}
+
+	filaments := make([]Filament, 0, len(plate.Filaments))
+	for _, usage := range plate.Filaments {
+		filament := Filament{ID: usage.ID, Type: usage.Type, Color: usage.Color}
+		if usage.ID > 0 && usage.ID <= len(p.Filaments) {
+			filament = p.Filaments[usage.ID-1]
+		}
+		filaments = append(filaments, filament)
+	}
+	sort.Slice(filaments, func(i, j int) bool { return filaments[i].ID < filaments[j].ID })
+	return filaments
+}
+
+// addPlateMetadata completes a plate with its plate_<n>.json file, which holds the
+// bounding boxes of the objects and the build plate type of sliced plates.
+func (f *File) addPlateMetadata(plate *Plate) error {
+	var metadata struct {
+		BedType     string   `json:"bed_type"`
+		BboxObjects []Object `json:"bbox_objects"`
+	}
+	err := f.readJSON(fmt.Sprintf("Metadata/plate_%d.json", plate.Index), &metadata)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	plate.BedType = metadata.BedType
+	if len(metadata.BboxObjects) == 0 {
+		return nil
+	}
+
+	// The plate metadata has the geometry of the objects; keep the names from the
+	// model settings, which are the ones shown in the slicer.
+	names := make(map[int]string, len(plate.Objects))
+	for _, object := range plate.Objects {
+		names[object.ID] = object.Name
+	}
+	plate.Objects = metadata.BboxObjects
+	for i := range plate.Objects {
+		if name := names[plate.Objects[i].ID]; name != "" {
+			plate.Objects[i].Name = name
+		}
+	}
+	return nil
+}
+
+func (f *File) projectFilaments() ([]Filament, error) {
+	var settings projectSettings
+	err := f.readJSON(projectSettingsFile, &settings)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	filaments := make([]Filament, 0, len(settings.FilamentType))
+	for i, filamentType := range settings.FilamentType {
+		c, _ := parseColor(index(settings.FilamentColour, i))
+		filaments = append(filaments, Filament{
+			ID:            i + 1,
+			Type:          filamentType,
+			Color:         c,
+			SettingID:     index(settings.FilamentSettings, i),
+			NozzleTempMin: atof(index(settings.NozzleTempLow, i)),
+			NozzleTempMax: atof(index(settings.NozzleTempHigh, i)),
+		})
+	}
+	return filaments, nil
+}
+
+func (f *File) readXML(name string, v any) error {
+	r, err := f.zip.Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+var hexColor = regexp.MustCompile(`^#?([0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// parseColor parses #RRGGBB and #RRGGBBAA colours.
+func parseColor(s string) (color.RGBA, bool) {
+	if !hexColor.MatchString(s) {
+		return color.RGBA{}, false
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, _ := strconv.ParseUint(hex, 16, 32)
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, true
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(strings.TrimSpace(s))
+	return i
+}
+
+func atof(s string) float64 {
+	// Multi-extruder printers list one value per nozzle.
+	s, _, _ = strings.Cut(s, ",")
+	f, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f
+}
+
+func index(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
@@ -0,0 +1,200 @@
+package threemf
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const modelSettingsConfig = `<?xml version="1.0" encoding="UTF-8"?>
+<config>
+  <object id="2">
+    <metadata key="name" value="Cube"/>
+    <metadata key="extruder" value="1"/>
+  </object>
+  <object id="4">
+    <metadata key="name" value="Cylinder"/>
+    <metadata key="extruder" value="2"/>
+  </object>
+  <object id="6">
+    <metadata key="name" value="Benchy"/>
+  </object>
+  <plate>
+    <metadata key="plater_id" value="1"/>
+    <metadata key="plater_name" value="Parts"/>
+    <metadata key="thumbnail_file" value="Metadata/plate_1.png"/>
+    <metadata key="gcode_file" value="Metadata/plate_1.gcode"/>
+    <model_instance>
+      <metadata key="object_id" value="2"/>
+      <metadata key="instance_id" value="0"/>
+      <metadata key="identify_id" value="143"/>
+    </model_instance>
+    <model_instance>
+      <metadata key="object_id" value="4"/>
+      <metadata key="instance_id" value="0"/>
+      <metadata key="identify_id" value="211"/>
+    </model_instance>
+  </plate>
+  <plate>
+    <metadata key="plater_id" value="2"/>
+    <metadata key="plater_name" value=""/>
+    <metadata key="thumbnail_file" value="Metadata/plate_2.png"/>
+    <metadata key="gcode_file" value=""/>
+    <model_instance>
+      <metadata key="object_id" value="6"/>
+      <metadata key="instance_id" value="0"/>
+      <metadata key="identify_id" value="305"/>
+    </model_instance>
+  </plate>
+</config>`
+
+const sliceInfoConfig = `<?xml version="1.0" encoding="UTF-8"?>
+<config>
+  <header>
+    <header_item key="X-BBL-Client-Type" value="slicer"/>
+    <header_item key="X-BBL-Client-Version" value="01.09.07.52"/>
+  </header>
+  <plate>
+    <metadata key="index" value="1"/>
+    <metadata key="printer_model_id" value="C12"/>
+    <metadata key="nozzle_diameters" value="0.4"/>
+    <metadata key="prediction" value="3725"/>
+    <metadata key="weight" value="24.56"/>
+    <object identify_id="143" name="Cube" skipped="false"/>
+    <object identify_id="211" name="Cylinder" skipped="false"/>
+    <filament id="1" tray_info_idx="GFA00" type="PLA" color="#FFFFFF" used_m="5.12" used_g="15.27"/>
+    <filament id="2" tray_info_idx="GFG99" type="PETG" color="#0A2989FF" used_m="3.02" used_g="9.29"/>
+  </plate>
+</config>`
+
+const projectSettingsConfig = `{
+	"filament_colour": ["#FFFFFF", "#0A2989", "#F72323"],
+	"filament_type": ["PLA", "PETG", "PLA"],
+	"filament_settings_id": ["Bambu PLA Basic @BBL P1P", "Generic PETG @BBL P1P", "Bambu PLA Basic @BBL P1P"],
+	"nozzle_temperature_range_low": ["190", "220", "190"],
+	"nozzle_temperature_range_high": ["230", "260", "230"],
+	"layer_height": "0.2"
+}`
+
+func TestFile_Project(t *testing.T) {
+	file := openProject(t, map[string]string{
+		"Metadata/model_settings.config":   modelSettingsConfig,
+		"Metadata/slice_info.config":       sliceInfoConfig,
+		"Metadata/project_settings.config": projectSettingsConfig,
+		"Metadata/plate_1.json":            plate1JSON,
+	})
+
+	project, err := file.Project()
+	require.NoError(t, err)
+
+	assert.Equal(t, "01.09.07.52", project.ClientVersion)
+	require.Len(t, project.Filaments, 3)
+	assert.Equal(t, Filament{
+		ID:            2,
+		Type:          "PETG",
+		Color:         color.RGBA{R: 0x0a, G: 0x29, B: 0x89, A: 0xff},
+		SettingID:     "Generic PETG @BBL P1P",
+		NozzleTempMin: 220,
+		NozzleTempMax: 260,
+	}, project.Filaments[1])
+
+	require.Len(t, project.Plates, 2)
+
+	plate := project.Plate(1)
+	require.NotNil(t, plate)
+	assert.True(t, plate.Sliced)
+	assert.Equal(t, "Parts", plate.Name)
+	assert.Equal(t, "Metadata/plate_1.gcode", plate.GcodeFile)
+	assert.Equal(t, "C12", plate.PrinterModelID)
+	assert.Equal(t, 0.4, plate.NozzleDiameter)
+	assert.Equal(t, "textured_plate", plate.BedType)
+	assert.Equal(t, time.Hour+2*time.Minute+5*time.Second, plate.Prediction)
+	assert.Equal(t, 24.56, plate.Weight)
+	assert.Equal(t, []FilamentUsage{
+		{ID: 1, Type: "PLA", Color: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, TrayInfoIdx: "GFA00", UsedMeters: 5.12, UsedGrams: 15.27},
+		{ID: 2, Type: "PETG", Color: color.RGBA{R: 0x0a, G: 0x29, B: 0x89, A: 0xff}, TrayInfoIdx: "GFG99", UsedMeters: 3.02, UsedGrams: 9.29},
+	}, plate.Filaments)
+	require.Len(t, plate.Objects, 2)
+	assert.Equal(t, Object{ID: 143, Name: "Cube", BoundingBox: [4]float64{90.5, 95.2, 110.5, 115.2}, Area: 400, LayerHeight: 0.2}, plate.Objects[0])
+
+	plate = project.Plate(2)
+	require.NotNil(t, plate)
+	assert.False(t, plate.Sliced)
+	assert.Zero(t, plate.Prediction)
+	assert.Empty(t, plate.Filaments)
+	assert.Equal(t, []Object{{ID: 305, Name: "Benchy"}}, plate.Objects)
+
+	assert.Nil(t, project.Plate(3))
+}
+
+func TestProject_PlateFilaments(t *testing.T) {
+	file := openProject(t, map[string]string{
+		"Metadata/model_settings.config":   modelSettingsConfig,
+		"Metadata/slice_info.config":       sliceInfoConfig,
+		"Metadata/project_settings.config": projectSettingsConfig,
+	})
+	project, err := file.Project()
+	require.NoError(t, err)
+
+	filaments := project.PlateFilaments(1)
+	require.Len(t, filaments, 2)
+	assert.Equal(t, project.Filaments[0], filaments[0])
+	assert.Equal(t, project.Filaments[1], filaments[1])
+
+	assert.Empty(t, project.PlateFilaments(2))
+	assert.Nil(t, project.PlateFilaments(3))
+
+	// Without project settings, the slice info is enough to map the filaments.
+	file = openProject(t, map[string]string{
+		"Metadata/model_settings.config": modelSettingsConfig,
+		"Metadata/slice_info.config":     sliceInfoConfig,
+	})
+	project, err = file.Project()
+	require.NoError(t, err)
+	assert.Equal(t, Filament{ID: 2, Type: "PETG", Color: color.RGBA{R: 0x0a, G: 0x29, B: 0x89, A: 0xff}}, project.PlateFilaments(1)[1])
+}
+
+func TestFile_ProjectWithoutSliceInfo(t *testing.T) {
+	file := openProject(t, map[string]string{"Metadata/model_settings.config": modelSettingsConfig})
+
+	project, err := file.Project()
+	require.NoError(t, err)
+	assert.Len(t, project.Plates, 2)
+	assert.Empty(t, project.Filaments)
+	assert.Empty(t, project.ClientVersion)
+}
+
+func TestFile_ProjectInvalid(t *testing.T) {
+	_, err := openProject(t, map[string]string{}).Project()
+	assert.Error(t, err)
+
+	_, err = openProject(t, map[string]string{
+		"Metadata/model_settings.config": modelSettingsConfig,
+		"Metadata/slice_info.config":     "<config><plate>",
+	}).Project()
+	assert.ErrorContains(t, err, "slice_info.config")
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  color.RGBA
+		valid bool
+	}{
+		{in: "#FF8000", want: color.RGBA{R: 0xff, G: 0x80, A: 0xff}, valid: true},
+		{in: "#ff800080", want: color.RGBA{R: 0xff, G: 0x80, A: 0x80}, valid: true},
+		{in: "00AE42", want: color.RGBA{G: 0xae, B: 0x42, A: 0xff}, valid: true},
+		{in: "#FFF"},
+		{in: "red"},
+		{in: ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseColor(tt.in)
+		assert.Equal(t, tt.valid, ok, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
@@ -0,0 +1,100 @@
+package threemf
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// A .3mf project sliced by Bambu Studio or OrcaSlicer is a zip archive. Next to the
+// 3D model, its Metadata directory holds the sliced G-code of each plate along with
+// a plate_<n>.json file describing the objects on the plate, a preview image and the
+// .config files described in project.go.
+
+// ErrPlateNotFound is returned when the project has no sliced plate with that number.
+var ErrPlateNotFound = errors.New("plate not found")
+
+// Object is an object printed on a plate.
+type Object struct {
+	ID          int        `json:"id"`           // Identifier used by the printer, e.g. to skip the object
+	Name        string     `json:"name"`         // Name of the object in the project
+	BoundingBox [4]float64 `json:"bbox"`         // Min x, min y, max x and max y of the object on the plate (mm)
+	Area        float64    `json:"area"`         // Area covered on the plate (mm²)
+	LayerHeight float64    `json:"layer_height"` // Layer height of the object (mm)
+}
+
+// File is an opened .3mf project.
+type File struct {
+	zip    *zip.Reader
+	closer io.Closer
+}
+
+// Open opens the .3mf project at path.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	file, err := NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	file.closer = f
+	return file, nil
+}
+
+// NewReader reads a .3mf project of the given size from r.
+func NewReader(r io.ReaderAt, size int64) (*File, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 3mf file: %w", err)
+	}
+	return &File{zip: archive}, nil
+}
+
+// Close closes the project if it was opened with Open.
+func (f *File) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
+
+// Objects returns the objects printed on a plate, numbered from 1.
+func (f *File) Objects(plate int) ([]Object, error) {
+	var metadata struct {
+		BboxObjects []Object `json:"bbox_objects"`
+	}
+	if err := f.readJSON(fmt.Sprintf("Metadata/plate_%d.json", plate), &metadata); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %d", ErrPlateNotFound, plate)
+		}
+		return nil, err
+	}
+	return metadata.BboxObjects, nil
+}
+
+func (f *File) readJSON(name string, v any) error {
+	r, err := f.zip.Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package threemf
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const plate1JSON = `{
+	"bbox_all":[90.5,95.2,165.4,160.8],
+	"bbox_objects":[
+		{"area":400.0,"bbox":[90.5,95.2,110.5,115.2],"id":143,"layer_height":0.2,"name":"Cube"},
+		{"area":706.8,"bbox":[135.4,130.8,165.4,160.8],"id":211,"layer_height":0.2,"name":"Cylinder"}
+	],
+	"bed_type":"textured_plate",
+	"filament_colors":["#FFFFFF","#000000"],
+	"filament_ids":[0,1],
+	"is_seq_print":false,
+	"nozzle_diameter":0.4,
+	"version":2
+}`
+
+// buildProject returns a .3mf archive holding the given files.
+func buildProject(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	return buf.Bytes()
+}
+
+func openProject(t *testing.T, files map[string]string) *File {
+	t.Helper()
+	data := buildProject(t, files)
+	file, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	return file
+}
+
+func TestFile_Objects(t *testing.T) {
+	file := openProject(t, map[string]string{"Metadata/plate_1.json": plate1JSON})
+
+	objects, err := file.Objects(1)
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	assert.Equal(t, Object{ID: 143, Name: "Cube", BoundingBox: [4]float64{90.5, 95.2, 110.5, 115.2}, Area: 400, LayerHeight: 0.2}, objects[0])
+	assert.Equal(t, "Cylinder", objects[1].Name)
+
+	_, err = file.Objects(2)
+	assert.ErrorIs(t, err, ErrPlateNotFound)
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cube.3mf")
+	require.NoError(t, os.WriteFile(path, buildProject(t, map[string]string{"Metadata/plate_1.json": plate1JSON}), 0o644))
+
+	file, err := Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	objects, err := file.Objects(1)
+	require.NoError(t, err)
+	assert.Len(t, objects, 2)
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing.3mf"))
+	assert.Error(t, err)
+}
+
+func TestNewReader_Invalid(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("not a zip")), 9)
+	assert.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package threemf
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io/fs"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/gcode"
+)
+
+// Thumbnail returns the preview image of a plate, numbered from 1. It is read from
+// Metadata/plate_<n>.png, or from the G-code of the plate when the project has no
+// PNG preview.
+func (f *File) Thumbnail(plate int) (image.Image, error) {
+	r, err := f.zip.Open(fmt.Sprintf("Metadata/plate_%d.png", plate))
+	if err == nil {
+		defer r.Close()
+		img, _, err := image.Decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thumbnail of plate %d: %w", plate, err)
+		}
+		return img, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	r, err = f.zip.Open(fmt.Sprintf("Metadata/plate_%d.gcode", plate))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %d", ErrPlateNotFound, plate)
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	thumbnails, err := gcode.ReadThumbnails(r)
+	if err != nil {
+		return nil, err
+	}
+	return gcode.Largest(thumbnails)
+}
@@ -0,0 +1,37 @@
+package threemf
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/gcode"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/gcode/gcodetest"
+)
+
+func TestFile_Thumbnail(t *testing.T) {
+	file := openProject(t, map[string]string{
+		"Metadata/plate_1.png":   string(gcodetest.PNG(32)),
+		"Metadata/plate_2.gcode": "; HEADER_BLOCK_START\n; HEADER_BLOCK_END\n" + gcodetest.Thumbnails(16, 64) + "G28\n",
+		"Metadata/plate_3.gcode": "G28\n",
+		"Metadata/plate_4.png":   "not an image",
+	})
+
+	img, err := file.Thumbnail(1)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 32, 32), img.Bounds())
+
+	img, err = file.Thumbnail(2)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 64, 64), img.Bounds(), "largest thumbnail expected")
+
+	_, err = file.Thumbnail(3)
+	assert.ErrorIs(t, err, gcode.ErrNoThumbnail)
+
+	_, err = file.Thumbnail(4)
+	assert.ErrorContains(t, err, "invalid thumbnail")
+
+	_, err = file.Thumbnail(5)
+	assert.ErrorIs(t, err, ErrPlateNotFound)
+}
@@ -1,68 +1,98 @@
 package bambulabs_cloud_api
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+	"sort"
+	"strings"
 	"sync"
+
+	"github.com/torbenconto/bambulabs_cloud_api/light"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+	"github.com/torbenconto/bambulabs_cloud_api/state"
 )
 
+// DefaultConcurrency is the number of printers a pool operates on at the same time,
+// see SetConcurrency.
+const DefaultConcurrency = 8
+
+// ErrPrinterNotFound is returned for a serial that is not in the pool.
+var ErrPrinterNotFound = errors.New("printer not found")
+
 type PrinterPool struct {
 	mu       sync.Mutex
 	printers sync.Map
 
-	mqttClient *mqtt.Client
+	mqttClient  *mqtt.Client
+	concurrency int
+	connected   bool // Between ConnectAll and DisconnectAll
 }
 
-func NewPrinterPool(config *mqtt.ClientConfig) *PrinterPool {
-	client := mqtt.NewClient(config)
-	return &PrinterPool{
-		mqttClient: client,
+// PoolErrors holds the errors of an operation on several printers, by serial.
+type PoolErrors map[string]error
+
+func (e PoolErrors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, serial := range e.serials() {
+		parts = append(parts, fmt.Sprintf("printer %s: %v", serial, e[serial]))
 	}
+	return strings.Join(parts, "; ")
 }
 
-func (p *PrinterPool) ConnectAll() error {
-	err := p.mqttClient.Connect()
-	if err != nil {
-		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+// Unwrap returns the errors, so that errors.Is and errors.As match any of them.
+func (e PoolErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, serial := range e.serials() {
+		errs = append(errs, e[serial])
 	}
+	return errs
+}
 
-	var wg sync.WaitGroup
-	errChan := make(chan error, 100)
+func (e PoolErrors) serials() []string {
+	serials := make([]string, 0, len(e))
+	for serial := range e {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+	return serials
+}
 
-	p.printers.Range(func(_, value interface{}) bool {
-		printer, ok := value.(*Printer)
-		if !ok {
-			return false
-		}
+func NewPrinterPool(config *mqtt.ClientConfig) *PrinterPool {
+	client := mqtt.NewClient(config)
+	return &PrinterPool{
+		mqttClient: client,
+	}
+}
 
-		wg.Add(1)
-		go func(p *Printer) {
-			defer wg.Done()
-			err := p.Connect()
-			if err != nil {
-				errChan <- fmt.Errorf("failed to connect to printer %s: %w", p.serial, err)
-			}
-		}(printer)
+// NewLocalPrinterPool returns an empty pool for printers reached over the LAN, see
+// AddLocalPrinter.
+func NewLocalPrinterPool() *PrinterPool {
+	return &PrinterPool{}
+}
 
-		return true
-	})
+func (p *PrinterPool) ConnectAll() error {
+	p.mu.Lock()
+	p.connected = true
+	p.mu.Unlock()
 
-	wg.Wait()
-	close(errChan)
-
-	var result error
-	for err := range errChan {
-		if result == nil {
-			result = err
-		} else {
-			result = fmt.Errorf("%v; %w", result, err)
+	if p.mqttClient != nil {
+		err := p.mqttClient.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to MQTT broker: %w", err)
 		}
 	}
 
-	return result
+	return p.Each(context.Background(), func(printer *Printer) error {
+		return printer.Connect()
+	})
 }
 
 func (p *PrinterPool) DisconnectAll() {
+	p.mu.Lock()
+	p.connected = false
+	p.mu.Unlock()
+
 	p.printers.Range(func(_, value interface{}) bool {
 		printer, ok := value.(*Printer)
 		if !ok {
@@ -73,59 +103,186 @@ func (p *PrinterPool) DisconnectAll() {
 		return true
 	})
 
-	p.mqttClient.Disconnect()
+	if p.mqttClient != nil {
+		p.mqttClient.Disconnect()
+	}
 }
 
-func (p *PrinterPool) AddPrinter(config *PrinterConfig) {
-	printer := NewPrinter(config)
-	p.printers.Store(config.SerialNumber, printer)
+// SetConcurrency sets the number of printers Each operates on at the same time,
+// DefaultConcurrency if n is not positive.
+func (p *PrinterPool) SetConcurrency(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.concurrency = n
 }
 
-func (p *PrinterPool) GetData() (map[string]Data, error) {
-	dataMap := make(map[string]Data)
-	var wg sync.WaitGroup
-	errChan := make(chan error, 100)
+// Each calls fn for every printer of the pool, running at most the configured
+// concurrency at the same time, and waits for all of them. Printers fn was not
+// called for because ctx was done fail with the context error. It returns nil if
+// every call succeeded and a PoolErrors otherwise.
+func (p *PrinterPool) Each(ctx context.Context, fn func(*Printer) error) error {
+	return p.run(ctx, p.GetPrinters(), nil, fn)
+}
 
-	p.printers.Range(func(_, value interface{}) bool {
-		printer, ok := value.(*Printer)
+// EachOf is like Each, for the printers with the given serials. Serials that are not
+// in the pool fail with ErrPrinterNotFound.
+func (p *PrinterPool) EachOf(ctx context.Context, serials []string, fn func(*Printer) error) error {
+	var printers []*Printer
+	errs := make(PoolErrors)
+	for _, serial := range serials {
+		printer, ok := p.GetPrinter(serial)
 		if !ok {
-			return false
+			errs[serial] = ErrPrinterNotFound
+			continue
+		}
+		printers = append(printers, printer)
+	}
+	return p.run(ctx, printers, errs, fn)
+}
+
+func (p *PrinterPool) run(ctx context.Context, printers []*Printer, errs PoolErrors, fn func(*Printer) error) error {
+	if errs == nil {
+		errs = make(PoolErrors)
+	}
+
+	p.mu.Lock()
+	concurrency := p.concurrency
+	p.mu.Unlock()
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, concurrency)
+	)
+	record := func(serial string, err error) {
+		mu.Lock()
+		errs[serial] = err
+		mu.Unlock()
+	}
+
+	for _, printer := range printers {
+		if ctx.Err() != nil {
+			record(printer.serial, ctx.Err())
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			record(printer.serial, ctx.Err())
+			continue
 		}
 
 		wg.Add(1)
 		go func(printer *Printer) {
 			defer wg.Done()
-			data, err := printer.Data()
-			if err != nil {
-				errChan <- fmt.Errorf("failed to get data from printer %s: %w", printer.serial, err)
-				return
+			defer func() { <-sem }()
+			if err := fn(printer); err != nil {
+				record(printer.serial, err)
 			}
-			p.mu.Lock()
-			dataMap[printer.serial] = data
-			p.mu.Unlock()
 		}(printer)
+	}
+	wg.Wait()
 
-		return true
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// PauseAll pauses every printer that is printing.
+func (p *PrinterPool) PauseAll(ctx context.Context) error {
+	return p.Each(ctx, func(printer *Printer) error {
+		if state.GcodeState(printer.mqttClient.Data(printer.serial).Print.GcodeState) != state.RUNNING {
+			return nil
+		}
+		return printer.Pause(ctx)
 	})
+}
 
-	wg.Wait()
-	close(errChan)
-
-	var result error
-	for err := range errChan {
-		if result == nil {
-			result = err
-		} else {
-			result = fmt.Errorf("%v; %w", result, err)
+// LightsOffAll turns off the chamber light of every printer.
+func (p *PrinterPool) LightsOffAll(ctx context.Context) error {
+	return p.Each(ctx, func(printer *Printer) error {
+		return printer.SetLight(ctx, light.ChamberLight, false)
+	})
+}
+
+// Preheat heats the bed and nozzle of the printers with the given serials to the
+// given temperatures (°C).
+func (p *PrinterPool) Preheat(ctx context.Context, serials []string, bed, nozzle int) error {
+	return p.EachOf(ctx, serials, func(printer *Printer) error {
+		if err := printer.SetBedTemperature(ctx, bed); err != nil {
+			return err
+		}
+		return printer.SetNozzleTemperature(ctx, nozzle)
+	})
+}
+
+// AddPrinter adds a printer reached through the cloud. Without a MqttClient in the
+// config, it uses the pool's client, which subscribes to the printer's reports right
+// away if it is already connected.
+func (p *PrinterPool) AddPrinter(config *PrinterConfig) error {
+	if config.MqttClient == nil {
+		config.MqttClient = p.mqttClient
+	}
+
+	printer := NewPrinter(config)
+	p.printers.Store(config.SerialNumber, printer)
+
+	if p.mqttClient != nil && printer.mqttClient == p.mqttClient {
+		if err := p.mqttClient.AddSerial(config.SerialNumber); err != nil {
+			return fmt.Errorf("failed to subscribe to printer %s: %w", config.SerialNumber, err)
+		}
+	}
+	return nil
+}
+
+// AddLocalPrinter adds a printer reached over the LAN, see NewLocalPrinter. It is
+// connected and disconnected along with the rest of the pool, right away if the
+// pool is already connected. The printer is added even if connecting fails.
+func (p *PrinterPool) AddLocalPrinter(ip, serial, accessCode string) (*Printer, error) {
+	printer := NewLocalPrinter(ip, serial, accessCode)
+	p.printers.Store(serial, printer)
+
+	p.mu.Lock()
+	connected := p.connected
+	p.mu.Unlock()
+	if connected {
+		if err := printer.Connect(); err != nil {
+			return printer, fmt.Errorf("failed to connect to printer %s: %w", serial, err)
 		}
 	}
+	return printer, nil
+}
+
+// GetData returns the data of each printer, by serial. Printers whose data cannot
+// be decoded are reported in a PoolErrors.
+func (p *PrinterPool) GetData() (map[string]Data, error) {
+	dataMap := make(map[string]Data)
+
+	err := p.Each(context.Background(), func(printer *Printer) error {
+		data, err := printer.Data()
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		dataMap[printer.serial] = data
+		p.mu.Unlock()
+		return nil
+	})
 
-	return dataMap, result
+	return dataMap, err
 }
 
-func (p *PrinterPool) GetPrinter(serialNumber string) *Printer {
-	printer, _ := p.printers.Load(serialNumber)
-	return printer.(*Printer)
+// GetPrinter returns the printer with the given serial, and whether it is in the pool.
+func (p *PrinterPool) GetPrinter(serialNumber string) (*Printer, bool) {
+	printer, ok := p.printers.Load(serialNumber)
+	if !ok {
+		return nil, false
+	}
+	return printer.(*Printer), true
 }
 
 func (p *PrinterPool) GetPrinters() []*Printer {
@@ -137,6 +294,23 @@ func (p *PrinterPool) GetPrinters() []*Printer {
 	return printers
 }
 
-func (p *PrinterPool) RemovePrinter(serialNumber string) {
-	p.printers.Delete(serialNumber)
+// RemovePrinter removes a printer from the pool. Printers on the LAN are
+// disconnected, and the pool's client stops receiving the reports of the others.
+func (p *PrinterPool) RemovePrinter(serialNumber string) error {
+	value, ok := p.printers.LoadAndDelete(serialNumber)
+	if !ok {
+		return nil
+	}
+
+	printer := value.(*Printer)
+	if printer.local {
+		printer.Disconnect()
+		return nil
+	}
+	if p.mqttClient != nil && printer.mqttClient == p.mqttClient {
+		if err := p.mqttClient.RemoveSerial(serialNumber); err != nil {
+			return fmt.Errorf("failed to unsubscribe from printer %s: %w", serialNumber, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,173 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+func newTestPool(n int) *PrinterPool {
+	pool := &PrinterPool{}
+	client := mqtt.NewClient(&mqtt.ClientConfig{})
+	for i := range n {
+		pool.AddPrinter(&PrinterConfig{MqttClient: client, SerialNumber: fmt.Sprintf("01P00A%09d", i)})
+	}
+	return pool
+}
+
+func TestPrinterPool_Each(t *testing.T) {
+	pool := newTestPool(20)
+	pool.SetConcurrency(3)
+
+	var running, peak, calls atomic.Int32
+	err := pool.Each(context.Background(), func(printer *Printer) error {
+		calls.Add(1)
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(20), calls.Load())
+	assert.Equal(t, int32(3), peak.Load())
+}
+
+func TestPrinterPool_EachErrors(t *testing.T) {
+	pool := newTestPool(3)
+	errOffline := errors.New("offline")
+
+	err := pool.Each(context.Background(), func(printer *Printer) error {
+		if printer.Serial() == "01P00A000000001" {
+			return nil
+		}
+		return errOffline
+	})
+
+	var errs PoolErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, PoolErrors{"01P00A000000000": errOffline, "01P00A000000002": errOffline}, errs)
+	assert.ErrorIs(t, err, errOffline)
+	assert.Equal(t, "printer 01P00A000000000: offline; printer 01P00A000000002: offline", err.Error())
+}
+
+func TestPrinterPool_EachCanceled(t *testing.T) {
+	pool := newTestPool(4)
+	pool.SetConcurrency(1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var calls atomic.Int32
+	err := pool.Each(ctx, func(printer *Printer) error {
+		calls.Add(1)
+		cancel()
+		return nil
+	})
+
+	var errs PoolErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 4-int(calls.Load()))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPrinterPool_EachOf(t *testing.T) {
+	pool := newTestPool(3)
+
+	var called []string
+	err := pool.EachOf(context.Background(), []string{"01P00A000000002", "missing"}, func(printer *Printer) error {
+		called = append(called, printer.Serial())
+		return nil
+	})
+
+	assert.Equal(t, []string{"01P00A000000002"}, called)
+	assert.Equal(t, PoolErrors{"missing": ErrPrinterNotFound}, err)
+}
+
+func TestPrinterPool_PauseAllIdle(t *testing.T) {
+	// Idle printers are left alone, so no command is sent.
+	assert.NoError(t, newTestPool(2).PauseAll(context.Background()))
+}
+
+func TestPrinterPool_PreheatUnknown(t *testing.T) {
+	err := newTestPool(1).Preheat(context.Background(), []string{"missing"}, 60, 220)
+	assert.ErrorIs(t, err, ErrPrinterNotFound)
+}
+
+func TestPrinterPool_GetDataEmpty(t *testing.T) {
+	data, err := (&PrinterPool{}).GetData()
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestPrinterPool_GetPrinter(t *testing.T) {
+	pool := newTestPool(1)
+
+	printer, ok := pool.GetPrinter("01P00A000000000")
+	assert.True(t, ok)
+	assert.Equal(t, "01P00A000000000", printer.Serial())
+
+	printer, ok = pool.GetPrinter("missing")
+	assert.False(t, ok)
+	assert.Nil(t, printer)
+}
+
+func TestPrinterPool_Membership(t *testing.T) {
+	pool := NewPrinterPool(&mqtt.ClientConfig{Serials: []string{"00M00A000000001"}})
+
+	// Printers without a client of their own share the pool's one, which learns
+	// their serial so that it subscribes to their reports.
+	require.NoError(t, pool.AddPrinter(&PrinterConfig{SerialNumber: "00M00A000000001"}))
+	require.NoError(t, pool.AddPrinter(&PrinterConfig{SerialNumber: "01P00A000000002"}))
+	assert.Equal(t, []string{"00M00A000000001", "01P00A000000002"}, pool.mqttClient.Serials())
+
+	printer, ok := pool.GetPrinter("01P00A000000002")
+	require.True(t, ok)
+	assert.Same(t, pool.mqttClient, printer.mqttClient)
+
+	require.NoError(t, pool.RemovePrinter("00M00A000000001"))
+	require.NoError(t, pool.RemovePrinter("missing"))
+	assert.Equal(t, []string{"01P00A000000002"}, pool.mqttClient.Serials())
+	_, ok = pool.GetPrinter("00M00A000000001")
+	assert.False(t, ok)
+
+	// Printers with their own client are left out of the pool's subscriptions.
+	require.NoError(t, pool.AddPrinter(&PrinterConfig{MqttClient: mqtt.NewClient(&mqtt.ClientConfig{}), SerialNumber: "03W00A000000003"}))
+	assert.Equal(t, []string{"01P00A000000002"}, pool.mqttClient.Serials())
+}
+
+func TestPrinterPool_LocalPrinters(t *testing.T) {
+	pool := NewLocalPrinterPool()
+
+	// Printers added before ConnectAll are connected by it.
+	printer, err := pool.AddLocalPrinter("127.0.0.1", "01P00A000000001", "12345678")
+	require.NoError(t, err)
+	assert.Equal(t, mqtt.Disconnected, printer.ConnectionState())
+
+	// Disconnecting a printer before the pool does must not panic.
+	printer.Disconnect()
+	assert.NotPanics(t, pool.DisconnectAll)
+
+	// Printers added to a connected pool are connected right away. Nothing listens
+	// on the port, so the attempt fails.
+	pool = NewLocalPrinterPool()
+	require.NoError(t, pool.ConnectAll())
+	_, err = pool.AddLocalPrinter("127.0.0.1", "01P00A000000002", "12345678")
+	assert.Error(t, err)
+	_, ok := pool.GetPrinter("01P00A000000002")
+	assert.True(t, ok)
+
+	assert.NotPanics(t, func() { require.NoError(t, pool.RemovePrinter("01P00A000000002")) })
+	pool.DisconnectAll()
+}
@@ -0,0 +1,217 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/ftp"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+	"github.com/torbenconto/bambulabs_cloud_api/state"
+)
+
+const printStatePollInterval = 500 * time.Millisecond
+
+// ErrPrinterBusy is returned when starting a print while another one is in progress.
+var ErrPrinterBusy = errors.New("printer is busy")
+
+type PrintOptions struct {
+	Plate                    int              // Plate of a .3mf file to print, defaults to 1
+	AmsMapping               []TrayLocation   // Tray feeding each filament of the file, in order (see Data.MapFilaments); empty to use the external spool
+	BedType                  string           // Build plate type, defaults to "auto"
+	Timelapse                bool             // Record a timelapse of the print
+	LayerInspect             bool             // Inspect the first layer (X1 series)
+	SkipBedLevelling         bool             // Skip the automatic bed levelling
+	SkipFlowCalibration      bool             // Skip the flow dynamics calibration
+	SkipVibrationCalibration bool             // Skip the vibration compensation calibration
+	Progress                 ftp.ProgressFunc // Called while the file is uploaded
+}
+
+// PrintError is returned when the printer reports a failure while starting a print.
+type PrintError struct {
+	State     state.GcodeState
+	ErrorCode string // mc_print_error_code reported by the printer
+	Reason    string
+}
+
+func (e *PrintError) Error() string {
+	msg := fmt.Sprintf("print failed to start (state %s", e.State)
+	if e.ErrorCode != "" && e.ErrorCode != "0" {
+		msg += ", error code " + e.ErrorCode
+	}
+	msg += ")"
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// PrintFile uploads a .3mf or .gcode file to the printer's SD card and starts
+// printing it. It returns once the printer reports the print as running, or with an
+// error if it reports a failure or ctx is done first. Only printers on the LAN can
+// receive files.
+func (p *Printer) PrintFile(ctx context.Context, file string, options PrintOptions) error {
+	name := filepath.Base(file)
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext != ".3mf" && ext != ".gcode" {
+		return fmt.Errorf("unsupported file type %q, expected .3mf or .gcode", ext)
+	}
+
+	current := state.GcodeState(p.mqttClient.Data(p.serial).Print.GcodeState)
+	if current == state.RUNNING || current == state.PAUSE || current == state.PREPARE {
+		return fmt.Errorf("%w: %s", ErrPrinterBusy, current)
+	}
+
+	files, err := p.Files()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var progress []ftp.ProgressFunc
+	if options.Progress != nil {
+		progress = append(progress, options.Progress)
+	}
+	if err := files.Upload(ctx, "/"+name, f, progress...); err != nil {
+		return err
+	}
+
+	var command *mqtt.Command
+	if ext == ".3mf" {
+		command = projectFileCommand(name, p.Model(), options)
+	} else {
+		command = gcodeFileCommand(name)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reply := make(chan error, 1)
+	go func() {
+		_, err := p.mqttClient.Request(ctx, p.serial, command)
+		reply <- err
+	}()
+
+	return awaitPrintStart(ctx, current, func() mqtt.Message { return p.mqttClient.Data(p.serial) }, reply)
+}
+
+// projectFileCommand builds the command printing a plate of a .3mf file stored at
+// the root of the SD card.
+func projectFileCommand(name string, model Model, options PrintOptions) *mqtt.Command {
+	plate := options.Plate
+	if plate <= 0 {
+		plate = 1
+	}
+	bedType := options.BedType
+	if bedType == "" {
+		bedType = "auto"
+	}
+
+	// The X1 series resolves files on its SD card, the other printers through their
+	// FTP server.
+	url := "ftp:///" + name
+	if model.IsX1() {
+		url = "file:///sdcard/" + name
+	}
+
+	mapping := make([]int, 0, len(options.AmsMapping))
+	for _, location := range options.AmsMapping {
+		mapping = append(mapping, amsMappingIndex(location))
+	}
+
+	return mqtt.NewCommand(mqtt.Print).
+		AddCommandField("project_file").
+		AddParamField(fmt.Sprintf("Metadata/plate_%d.gcode", plate)).
+		AddField("url", url).
+		AddField("subtask_name", strings.TrimSuffix(name, filepath.Ext(name))).
+		AddField("project_id", "0").
+		AddField("profile_id", "0").
+		AddField("task_id", "0").
+		AddField("subtask_id", "0").
+		AddField("md5", "").
+		AddField("bed_type", bedType).
+		AddField("timelapse", options.Timelapse).
+		AddField("bed_leveling", !options.SkipBedLevelling).
+		AddField("flow_cali", !options.SkipFlowCalibration).
+		AddField("vibration_cali", !options.SkipVibrationCalibration).
+		AddField("layer_inspect", options.LayerInspect).
+		AddField("use_ams", len(mapping) > 0).
+		AddField("ams_mapping", mapping)
+}
+
+// gcodeFileCommand builds the command printing a G-code file stored at the root of
+// the SD card.
+func gcodeFileCommand(name string) *mqtt.Command {
+	return mqtt.NewCommand(mqtt.Print).
+		AddCommandField("gcode_file").
+		AddParamField("/sdcard/" + name)
+}
+
+// amsMappingIndex returns the index of a tray in the ams_mapping of a print command:
+// the global tray number for AMS units, the unit ID for AMS HT units, 254 for the
+// external spool and -1 for filaments that are not used.
+func amsMappingIndex(location TrayLocation) int {
+	switch {
+	case location == NoTray:
+		return -1
+	case location.External():
+		return trayNowExternal
+	case location.AmsID >= amsHTFirstID:
+		return location.AmsID
+	default:
+		return location.AmsID*traysPerAms + location.TrayID
+	}
+}
+
+// awaitPrintStart waits until the printer reports the print as running. A failure is
+// only considered once the state changed from initial, the state before the print
+// was requested, so that the outcome of a previous print is not mistaken for the
+// new one.
+func awaitPrintStart(ctx context.Context, initial state.GcodeState, data func() mqtt.Message, reply <-chan error) error {
+	ticker := time.NewTicker(printStatePollInterval)
+	defer ticker.Stop()
+
+	changed := false
+
+	for {
+		message := data()
+		current := state.GcodeState(message.Print.GcodeState)
+		if current != initial {
+			changed = true
+		}
+
+		switch {
+		case current == state.RUNNING:
+			return nil
+		case current == state.FAILED && changed:
+			return &PrintError{
+				State:     current,
+				ErrorCode: message.Print.McPrintErrorCode,
+				Reason:    message.Print.FailReason,
+			}
+		}
+
+		select {
+		case err := <-reply:
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return &PrintError{State: current, ErrorCode: message.Print.McPrintErrorCode, Reason: err.Error()}
+			}
+			reply = nil
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
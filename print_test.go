@@ -0,0 +1,131 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+	"github.com/torbenconto/bambulabs_cloud_api/state"
+)
+
+func commandFields(t *testing.T, command *mqtt.Command) map[string]any {
+	t.Helper()
+	raw, err := command.JSON()
+	require.NoError(t, err)
+
+	var message map[string]map[string]any
+	require.NoError(t, json.Unmarshal([]byte(raw), &message))
+	return message[string(command.Type)]
+}
+
+func TestProjectFileCommand(t *testing.T) {
+	fields := commandFields(t, projectFileCommand("cube.3mf", ModelP1S, PrintOptions{
+		Plate:               2,
+		AmsMapping:          []TrayLocation{{AmsID: 0, TrayID: 2}, {AmsID: 1, TrayID: 0}, {AmsID: -1}, {AmsID: 128}, NoTray},
+		SkipFlowCalibration: true,
+	}))
+
+	assert.Equal(t, "project_file", fields["command"])
+	assert.Equal(t, "Metadata/plate_2.gcode", fields["param"])
+	assert.Equal(t, "ftp:///cube.3mf", fields["url"])
+	assert.Equal(t, "cube", fields["subtask_name"])
+	assert.Equal(t, "auto", fields["bed_type"])
+	assert.Equal(t, true, fields["use_ams"])
+	assert.Equal(t, []any{2.0, 4.0, 254.0, 128.0, -1.0}, fields["ams_mapping"])
+	assert.Equal(t, true, fields["bed_leveling"])
+	assert.Equal(t, false, fields["flow_cali"])
+	assert.Equal(t, false, fields["timelapse"])
+}
+
+func TestProjectFileCommand_X1(t *testing.T) {
+	fields := commandFields(t, projectFileCommand("cube.3mf", ModelX1C, PrintOptions{}))
+
+	assert.Equal(t, "file:///sdcard/cube.3mf", fields["url"])
+	assert.Equal(t, "Metadata/plate_1.gcode", fields["param"])
+	assert.Equal(t, false, fields["use_ams"])
+	assert.Equal(t, []any{}, fields["ams_mapping"])
+}
+
+func TestGcodeFileCommand(t *testing.T) {
+	fields := commandFields(t, gcodeFileCommand("cube.gcode"))
+
+	assert.Equal(t, "gcode_file", fields["command"])
+	assert.Equal(t, "/sdcard/cube.gcode", fields["param"])
+}
+
+// states returns a data function reporting each state in turn, then the last one.
+func states(gcodeStates ...string) func() mqtt.Message {
+	var mu sync.Mutex
+	return func() mqtt.Message {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var m mqtt.Message
+		m.Print.GcodeState = gcodeStates[0]
+		if gcodeStates[0] == "FAILED" {
+			m.Print.McPrintErrorCode = "50348044"
+		}
+		if len(gcodeStates) > 1 {
+			gcodeStates = gcodeStates[1:]
+		}
+		return m
+	}
+}
+
+func TestAwaitPrintStart(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("running", func(t *testing.T) {
+		err := awaitPrintStart(ctx, state.FINISH, states("FINISH", "PREPARE", "RUNNING"), make(chan error, 1))
+		assert.NoError(t, err)
+	})
+
+	t.Run("previous failure ignored", func(t *testing.T) {
+		err := awaitPrintStart(ctx, state.FAILED, states("FAILED", "PREPARE", "RUNNING"), make(chan error, 1))
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		err := awaitPrintStart(ctx, state.IDLE, states("PREPARE", "FAILED"), make(chan error, 1))
+
+		var printErr *PrintError
+		require.ErrorAs(t, err, &printErr)
+		assert.Equal(t, state.FAILED, printErr.State)
+		assert.Equal(t, "50348044", printErr.ErrorCode)
+	})
+
+	t.Run("command rejected", func(t *testing.T) {
+		reply := make(chan error, 1)
+		reply <- errors.New("command failed: file not found")
+
+		err := awaitPrintStart(ctx, state.IDLE, states("IDLE"), reply)
+
+		var printErr *PrintError
+		require.ErrorAs(t, err, &printErr)
+		assert.Contains(t, printErr.Error(), "file not found")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		err := awaitPrintStart(ctx, state.IDLE, states("IDLE"), make(chan error, 1))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestPrintFile_Rejected(t *testing.T) {
+	printer := NewPrinter(&PrinterConfig{MqttClient: mqtt.NewClient(&mqtt.ClientConfig{}), SerialNumber: "01P00A000000000"})
+
+	err := printer.PrintFile(context.Background(), "cube.stl", PrintOptions{})
+	assert.ErrorContains(t, err, "unsupported file type")
+
+	err = printer.PrintFile(context.Background(), "cube.3mf", PrintOptions{})
+	assert.ErrorIs(t, err, ErrNotLocal)
+}
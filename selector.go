@@ -0,0 +1,169 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+// ErrInvalidSelector is returned for selectors that cannot be parsed.
+var ErrInvalidSelector = errors.New("invalid selector")
+
+// Selector picks printers of a pool from a list of comma-separated conditions, all
+// of which must hold, such as "model=X1C,tag=lab2,state=IDLE". A condition is a key
+// and a value separated by "=", or by "!=" to exclude the value. Values are compared
+// case-insensitively. The keys are:
+//
+//   - serial, name, model: the serial, cloud name and model of the printer,
+//   - tag: a label attached to the printer (see Printer.AddTags),
+//   - online, print_status: the cloud status of the printer (see Device),
+//   - local: whether the printer is reached over the LAN,
+//   - state: the gcode state reported by the printer (IDLE, RUNNING...),
+//
+// and any other key is the path of a field of Data, using its JSON names: "sdcard",
+// "nozzle_diameter", "camera.enabled" or "ams.0.humidity".
+type Selector struct {
+	conditions []condition
+}
+
+type condition struct {
+	key    string
+	value  string
+	negate bool
+}
+
+// ParseSelector parses a selector. The empty selector matches every printer.
+func ParseSelector(s string) (Selector, error) {
+	var selector Selector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(term, "=")
+		if !ok {
+			return Selector{}, fmt.Errorf("%w: %q is not key=value", ErrInvalidSelector, term)
+		}
+		c := condition{key: strings.ToLower(strings.TrimSpace(key)), value: strings.TrimSpace(value)}
+		if strings.HasSuffix(c.key, "!") {
+			c.key = strings.TrimSpace(strings.TrimSuffix(c.key, "!"))
+			c.negate = true
+		}
+		if c.key == "" {
+			return Selector{}, fmt.Errorf("%w: %q has no key", ErrInvalidSelector, term)
+		}
+		selector.conditions = append(selector.conditions, c)
+	}
+	return selector, nil
+}
+
+// Matches reports whether a printer satisfies every condition of the selector.
+func (s Selector) Matches(printer *Printer) bool {
+	var data json.RawMessage
+	device := printer.Device()
+	for _, c := range s.conditions {
+		var match bool
+		switch c.key {
+		case "serial":
+			match = strings.EqualFold(printer.serial, c.value)
+		case "name":
+			match = strings.EqualFold(device.Name, c.value)
+		case "model":
+			model := printer.Model()
+			match = model != ModelUnknown && (strings.EqualFold(string(model), c.value) || ModelFromName(c.value) == model)
+		case "tag":
+			match = printer.HasTag(c.value)
+		case "online":
+			match = equalBool(device.Online, c.value)
+		case "print_status":
+			match = strings.EqualFold(device.PrintStatus, c.value)
+		case "local":
+			match = equalBool(printer.local, c.value)
+		case "state":
+			match = printer.mqttClient != nil && strings.EqualFold(printer.mqttClient.Data(printer.serial).Print.GcodeState, c.value)
+		default:
+			if data == nil {
+				data = printer.selectorData()
+			}
+			match = equalDataField(data, c.key, c.value)
+		}
+
+		if match == c.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// selectorData returns the JSON encoding of the printer's Data, or an empty object
+// if it cannot be decoded.
+func (p *Printer) selectorData() json.RawMessage {
+	if p.mqttClient != nil {
+		if data, err := p.Data(); err == nil {
+			if raw, err := json.Marshal(data); err == nil {
+				return raw
+			}
+		}
+	}
+	return json.RawMessage("{}")
+}
+
+func equalDataField(data json.RawMessage, path, want string) bool {
+	value, err := mqtt.Lookup[any](data, path)
+	if err != nil {
+		return false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return strings.EqualFold(v, want)
+	case bool:
+		return equalBool(v, want)
+	case float64:
+		f, err := strconv.ParseFloat(want, 64)
+		return err == nil && f == v
+	case nil:
+		return want == "" || want == "null"
+	default:
+		return false
+	}
+}
+
+func equalBool(v bool, want string) bool {
+	b, err := strconv.ParseBool(want)
+	return err == nil && b == v
+}
+
+// Select returns the printers matching a selector, sorted by serial. See Selector
+// for the syntax.
+func (p *PrinterPool) Select(selector string) ([]*Printer, error) {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var printers []*Printer
+	for _, printer := range p.GetPrinters() {
+		if s.Matches(printer) {
+			printers = append(printers, printer)
+		}
+	}
+	sort.Slice(printers, func(i, j int) bool { return printers[i].serial < printers[j].serial })
+	return printers, nil
+}
+
+// EachSelected is like Each, for the printers matching a selector.
+func (p *PrinterPool) EachSelected(ctx context.Context, selector string, fn func(*Printer) error) error {
+	printers, err := p.Select(selector)
+	if err != nil {
+		return err
+	}
+	return p.run(ctx, printers, nil, fn)
+}
@@ -0,0 +1,129 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+func selectorPool() *PrinterPool {
+	client := mqtt.NewClient(&mqtt.ClientConfig{})
+	pool := &PrinterPool{}
+	pool.AddPrinter(&PrinterConfig{
+		MqttClient:   client,
+		SerialNumber: "00M00A000000001",
+		Device:       Device{DevID: "00M00A000000001", Name: "Lab X1", Online: true, DevModelName: "BL-P001"},
+		Tags:         []string{"lab2", "ABS enclosure"},
+	})
+	pool.AddPrinter(&PrinterConfig{
+		MqttClient:   client,
+		SerialNumber: "01P00A000000002",
+		Device:       Device{DevID: "01P00A000000002", Name: "Office P1S", Online: false, PrintStatus: "IDLE"},
+		Tags:         []string{"lab2"},
+	})
+	pool.AddPrinter(&PrinterConfig{
+		MqttClient:   client,
+		SerialNumber: "ZZZ00A000000003",
+		Device:       Device{DevID: "ZZZ00A000000003", Name: "Prototype", DevModelName: "N2S"},
+	})
+	return pool
+}
+
+func selectSerials(t *testing.T, pool *PrinterPool, selector string) []string {
+	t.Helper()
+	printers, err := pool.Select(selector)
+	require.NoError(t, err)
+
+	serials := []string{}
+	for _, printer := range printers {
+		serials = append(serials, printer.Serial())
+	}
+	return serials
+}
+
+func TestPrinterPool_Select(t *testing.T) {
+	pool := selectorPool()
+	all := []string{"00M00A000000001", "01P00A000000002", "ZZZ00A000000003"}
+
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{selector: "", want: all},
+		{selector: "tag=lab2", want: []string{"00M00A000000001", "01P00A000000002"}},
+		{selector: "tag=abs enclosure", want: []string{"00M00A000000001"}},
+		{selector: "tag!=lab2", want: []string{"ZZZ00A000000003"}},
+		{selector: "model=X1C", want: []string{"00M00A000000001"}},
+		{selector: "model=C12", want: []string{"01P00A000000002"}},
+		{selector: "model=A1", want: []string{"ZZZ00A000000003"}},
+		{selector: "model=X1C,tag=lab2", want: []string{"00M00A000000001"}},
+		{selector: "model=P1S, tag=lab2", want: []string{"01P00A000000002"}},
+		{selector: "model=X1C,tag=lab3", want: []string{}},
+		{selector: "name=office p1s", want: []string{"01P00A000000002"}},
+		{selector: "online=true", want: []string{"00M00A000000001"}},
+		{selector: "print_status=idle", want: []string{"01P00A000000002"}},
+		{selector: "serial=01P00A000000002", want: []string{"01P00A000000002"}},
+		{selector: "local=false", want: all},
+		// No report has been received, so the printers have no state and empty data.
+		{selector: "state=IDLE", want: []string{}},
+		{selector: "sdcard=false", want: all},
+		{selector: "ams.0.humidity=1", want: []string{}},
+		{selector: "ams.0.humidity!=1", want: all},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			assert.Equal(t, tt.want, selectSerials(t, pool, tt.selector))
+		})
+	}
+}
+
+func TestParseSelector_Invalid(t *testing.T) {
+	for _, selector := range []string{"model", "tag=lab2,=x", "!=IDLE"} {
+		_, err := ParseSelector(selector)
+		assert.ErrorIs(t, err, ErrInvalidSelector, selector)
+	}
+
+	_, err := selectorPool().Select("lab2")
+	assert.ErrorIs(t, err, ErrInvalidSelector)
+}
+
+func TestEqualDataField(t *testing.T) {
+	data := json.RawMessage(`{"gcode_state":"RUNNING","sdcard":true,"nozzle_diameter":"0.4","print_percent_done":42,"camera":{"enabled":false},"ams":[{"humidity":3}],"hms":null}`)
+
+	assert.True(t, equalDataField(data, "gcode_state", "running"))
+	assert.True(t, equalDataField(data, "sdcard", "true"))
+	assert.False(t, equalDataField(data, "sdcard", "yes"))
+	assert.True(t, equalDataField(data, "nozzle_diameter", "0.4"))
+	assert.True(t, equalDataField(data, "print_percent_done", "42"))
+	assert.False(t, equalDataField(data, "print_percent_done", "42%"))
+	assert.True(t, equalDataField(data, "camera.enabled", "false"))
+	assert.True(t, equalDataField(data, "ams.0.humidity", "3"))
+	assert.True(t, equalDataField(data, "hms", "null"))
+	assert.False(t, equalDataField(data, "ams", "3"))
+	assert.False(t, equalDataField(data, "missing", ""))
+}
+
+func TestPrinterPool_EachSelected(t *testing.T) {
+	pool := selectorPool()
+
+	var called []string
+	err := pool.EachSelected(context.Background(), "tag=abs enclosure", func(printer *Printer) error {
+		called = append(called, printer.Serial())
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"00M00A000000001"}, called)
+
+	assert.ErrorIs(t, pool.EachSelected(context.Background(), "tag", nil), ErrInvalidSelector)
+}
+
+func TestPrinter_ModelFromDevice(t *testing.T) {
+	printer := NewPrinter(&PrinterConfig{SerialNumber: "ZZZ00A000000003", Device: Device{DevModelName: "N2S"}})
+	assert.Equal(t, ModelA1, printer.Model())
+	assert.Equal(t, "N2S", printer.Device().DevModelName)
+}
@@ -0,0 +1,149 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultSyncInterval is the interval at which PrinterPool.Sync lists the devices
+// bound to the account when none is given.
+const DefaultSyncInterval = time.Minute
+
+// ErrNoCloudConnection is returned when syncing a pool that has no connection to the
+// cloud broker, such as one created by NewLocalPrinterPool.
+var ErrNoCloudConnection = errors.New("pool has no cloud connection")
+
+type SyncEventType int
+
+const (
+	PrinterAdded   SyncEventType = iota // A printer was bound to the account and added to the pool
+	PrinterRemoved                      // A printer was unbound from the account and removed from the pool
+	PrinterUpdated                      // The name, online or print status of a printer changed
+	SyncFailed                          // The devices could not be listed or a printer could not be added or removed
+)
+
+func (t SyncEventType) String() string {
+	switch t {
+	case PrinterAdded:
+		return "added"
+	case PrinterRemoved:
+		return "removed"
+	case PrinterUpdated:
+		return "updated"
+	case SyncFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// SyncEvent is a change made to a pool by PrinterPool.Sync.
+type SyncEvent struct {
+	Type   SyncEventType
+	Serial string // Serial of the printer, empty if listing the devices failed
+	Device Device // Cloud metadata of the printer; for removals, the last one known
+	Err    error  // Set for SyncFailed
+}
+
+// Sync keeps the pool in line with the devices bound to the account until ctx is
+// done, listing them with list (such as Client.ListDevices) right away and then at
+// every interval, DefaultSyncInterval if it is not positive. See SyncDevices for the
+// changes made. The changes are sent on the returned channel, which is closed once
+// ctx is done; it must be drained for the sync to progress.
+func (p *PrinterPool) Sync(ctx context.Context, list func() ([]Device, error), interval time.Duration) <-chan SyncEvent {
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	events := make(chan SyncEvent, 16)
+
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			var changes []SyncEvent
+			devices, err := list()
+			if err != nil {
+				changes = []SyncEvent{{Type: SyncFailed, Err: fmt.Errorf("failed to list devices: %w", err)}}
+			} else {
+				changes = p.SyncDevices(devices)
+			}
+
+			for _, event := range changes {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+// SyncPool keeps a pool returned by GetPrintersAsPool in line with the devices bound
+// to the account, see PrinterPool.Sync.
+func (c *Client) SyncPool(ctx context.Context, pool *PrinterPool, interval time.Duration) <-chan SyncEvent {
+	return pool.Sync(ctx, c.ListDevices, interval)
+}
+
+// SyncDevices updates the pool from the list of devices bound to the account: new
+// devices are added to the pool and subscribed to, printers reached through the
+// cloud that are no longer listed are removed, and the cloud metadata of the others
+// is updated. Printers on the LAN are left alone. It returns the changes made,
+// ordered by serial.
+func (p *PrinterPool) SyncDevices(devices []Device) []SyncEvent {
+	if p.mqttClient == nil {
+		return []SyncEvent{{Type: SyncFailed, Err: ErrNoCloudConnection}}
+	}
+
+	var events []SyncEvent
+	listed := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		listed[device.DevID] = true
+
+		printer, ok := p.GetPrinter(device.DevID)
+		if !ok {
+			err := p.AddPrinter(&PrinterConfig{MqttClient: p.mqttClient, SerialNumber: device.DevID, Device: device})
+			if err != nil {
+				events = append(events, SyncEvent{Type: SyncFailed, Serial: device.DevID, Device: device, Err: err})
+				continue
+			}
+			events = append(events, SyncEvent{Type: PrinterAdded, Serial: device.DevID, Device: device})
+			continue
+		}
+
+		if printer.local {
+			continue
+		}
+		if printer.setDevice(device) {
+			events = append(events, SyncEvent{Type: PrinterUpdated, Serial: device.DevID, Device: device})
+		}
+	}
+
+	for _, printer := range p.GetPrinters() {
+		if printer.local || listed[printer.serial] {
+			continue
+		}
+		device := printer.Device()
+		if err := p.RemovePrinter(printer.serial); err != nil {
+			events = append(events, SyncEvent{Type: SyncFailed, Serial: printer.serial, Device: device, Err: err})
+			continue
+		}
+		events = append(events, SyncEvent{Type: PrinterRemoved, Serial: printer.serial, Device: device})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Serial < events[j].Serial })
+	return events
+}
@@ -0,0 +1,97 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+func TestPrinterPool_SyncDevices(t *testing.T) {
+	pool := NewPrinterPool(&mqtt.ClientConfig{})
+	require.NoError(t, pool.AddPrinter(&PrinterConfig{SerialNumber: "00M00A000000001", Device: Device{DevID: "00M00A000000001", Name: "X1", Online: true}}))
+	require.NoError(t, pool.AddPrinter(&PrinterConfig{SerialNumber: "01P00A000000002", Device: Device{DevID: "01P00A000000002", Name: "P1S"}}))
+	local, err := pool.AddLocalPrinter("192.168.1.20", "03W00A000000004", "12345678")
+	require.NoError(t, err)
+
+	events := pool.SyncDevices([]Device{
+		{DevID: "00M00A000000001", Name: "X1", Online: false, PrintStatus: "IDLE"},
+		{DevID: "030", Name: "A1 mini"},
+		{DevID: "03W00A000000004", Name: "X1E", Online: true},
+	})
+
+	require.Len(t, events, 3)
+	assert.Equal(t, SyncEvent{Type: PrinterUpdated, Serial: "00M00A000000001", Device: Device{DevID: "00M00A000000001", Name: "X1", PrintStatus: "IDLE"}}, events[0])
+	assert.Equal(t, SyncEvent{Type: PrinterRemoved, Serial: "01P00A000000002", Device: Device{DevID: "01P00A000000002", Name: "P1S"}}, events[1])
+	assert.Equal(t, SyncEvent{Type: PrinterAdded, Serial: "030", Device: Device{DevID: "030", Name: "A1 mini"}}, events[2])
+
+	printer, ok := pool.GetPrinter("00M00A000000001")
+	require.True(t, ok)
+	assert.Equal(t, "IDLE", printer.Device().PrintStatus)
+
+	_, ok = pool.GetPrinter("01P00A000000002")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"00M00A000000001", "030"}, pool.mqttClient.Serials())
+
+	// LAN printers keep their own metadata and are never removed.
+	assert.Zero(t, local.Device())
+	assert.Empty(t, pool.SyncDevices([]Device{{DevID: "00M00A000000001", Name: "X1", PrintStatus: "IDLE"}, {DevID: "030", Name: "A1 mini"}}))
+	_, ok = pool.GetPrinter("03W00A000000004")
+	assert.True(t, ok)
+}
+
+func TestPrinterPool_SyncDevicesLocalPool(t *testing.T) {
+	events := NewLocalPrinterPool().SyncDevices([]Device{{DevID: "00M00A000000001"}})
+	require.Len(t, events, 1)
+	assert.Equal(t, SyncFailed, events[0].Type)
+	assert.ErrorIs(t, events[0].Err, ErrNoCloudConnection)
+}
+
+func TestPrinterPool_Sync(t *testing.T) {
+	pool := NewPrinterPool(&mqtt.ClientConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	errUnauthorized := errors.New("401 Unauthorized")
+	list := func() ([]Device, error) {
+		switch calls.Add(1) {
+		case 1:
+			return []Device{{DevID: "00M00A000000001"}}, nil
+		case 2:
+			return nil, errUnauthorized
+		default:
+			return []Device{}, nil
+		}
+	}
+
+	events := pool.Sync(ctx, list, time.Millisecond)
+
+	receive := func() SyncEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return SyncEvent{}
+		}
+	}
+
+	assert.Equal(t, SyncEvent{Type: PrinterAdded, Serial: "00M00A000000001", Device: Device{DevID: "00M00A000000001"}}, receive())
+
+	event := receive()
+	assert.Equal(t, SyncFailed, event.Type)
+	assert.ErrorIs(t, event.Err, errUnauthorized)
+
+	assert.Equal(t, PrinterRemoved, receive().Type)
+
+	cancel()
+	for range events {
+	}
+}
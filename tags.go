@@ -0,0 +1,68 @@
+package bambulabs_cloud_api
+
+import (
+	"slices"
+	"strings"
+)
+
+// Tags returns the labels attached to the printer, such as "lab2" or "ABS enclosure".
+func (p *Printer) Tags() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.tags)
+}
+
+// HasTag reports whether a label is attached to the printer. Labels are compared
+// case-insensitively.
+func (p *Printer) HasTag(tag string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tagIndex(tag) >= 0
+}
+
+// AddTags attaches labels to the printer, ignoring the ones it already has.
+func (p *Printer) AddTags(tags ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && p.tagIndex(tag) < 0 {
+			p.tags = append(p.tags, tag)
+		}
+	}
+}
+
+// RemoveTags detaches labels from the printer.
+func (p *Printer) RemoveTags(tags ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, tag := range tags {
+		if i := p.tagIndex(strings.TrimSpace(tag)); i >= 0 {
+			p.tags = slices.Delete(p.tags, i, i+1)
+		}
+	}
+}
+
+func (p *Printer) tagIndex(tag string) int {
+	return slices.IndexFunc(p.tags, func(t string) bool { return strings.EqualFold(t, tag) })
+}
+
+// Tag attaches labels to the printer with the given serial.
+func (p *PrinterPool) Tag(serial string, tags ...string) error {
+	printer, ok := p.GetPrinter(serial)
+	if !ok {
+		return ErrPrinterNotFound
+	}
+	printer.AddTags(tags...)
+	return nil
+}
+
+// Untag detaches labels from the printer with the given serial.
+func (p *PrinterPool) Untag(serial string, tags ...string) error {
+	printer, ok := p.GetPrinter(serial)
+	if !ok {
+		return ErrPrinterNotFound
+	}
+	printer.RemoveTags(tags...)
+	return nil
+}
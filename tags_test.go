@@ -0,0 +1,35 @@
+package bambulabs_cloud_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrinter_Tags(t *testing.T) {
+	printer := NewPrinter(&PrinterConfig{SerialNumber: "01P00A000000000", Tags: []string{"lab2", " ", "ABS enclosure"}})
+
+	printer.AddTags("LAB2", "night shift")
+	assert.Equal(t, []string{"lab2", "ABS enclosure", "night shift"}, printer.Tags())
+	assert.True(t, printer.HasTag("abs enclosure"))
+
+	printer.RemoveTags("Lab2", "unknown")
+	assert.Equal(t, []string{"ABS enclosure", "night shift"}, printer.Tags())
+	assert.False(t, printer.HasTag("lab2"))
+}
+
+func TestPrinterPool_Tag(t *testing.T) {
+	pool := newTestPool(1)
+
+	printer, ok := pool.GetPrinter("01P00A000000000")
+	require.True(t, ok)
+
+	assert.NoError(t, pool.Tag("01P00A000000000", "lab2"))
+	assert.True(t, printer.HasTag("lab2"))
+	assert.NoError(t, pool.Untag("01P00A000000000", "lab2"))
+	assert.Empty(t, printer.Tags())
+
+	assert.ErrorIs(t, pool.Tag("missing", "lab2"), ErrPrinterNotFound)
+	assert.ErrorIs(t, pool.Untag("missing", "lab2"), ErrPrinterNotFound)
+}
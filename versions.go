@@ -0,0 +1,68 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+type FirmwareModule struct {
+	Name            string `json:"name"`             // Module name (ota, mc, th, ams/0, ...)
+	ProjectName     string `json:"project_name"`     // Internal project name of the module
+	SoftwareVersion string `json:"software_version"` // Installed firmware version
+	HardwareVersion string `json:"hardware_version"` // Hardware revision
+	SerialNumber    string `json:"serial_number"`    // Serial number of the module
+}
+
+// amsModulePrefixes are the module names used by the different AMS models.
+var amsModulePrefixes = []string{"ams", "ams_f1", "n3f", "n3s"}
+
+// AmsID returns the ID of the AMS unit the module belongs to, if any.
+func (m FirmwareModule) AmsID() (int, bool) {
+	prefix, index, ok := strings.Cut(m.Name, "/")
+	if !ok {
+		return 0, false
+	}
+
+	for _, p := range amsModulePrefixes {
+		if prefix == p {
+			id, err := strconv.Atoi(index)
+			return id, err == nil
+		}
+	}
+
+	return 0, false
+}
+
+// Versions requests the firmware versions of all modules of the printer, including
+// the serial numbers of connected AMS units.
+func (p *Printer) Versions(ctx context.Context) ([]FirmwareModule, error) {
+	command := mqtt.NewCommand(mqtt.Info).AddCommandField("get_version")
+
+	raw, err := p.mqttClient.Request(ctx, p.serial, command)
+	if err != nil {
+		return nil, fmt.Errorf("get_version failed: %w", err)
+	}
+
+	var reply mqtt.InfoMessage
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return nil, fmt.Errorf("get_version failed: %w", err)
+	}
+
+	modules := make([]FirmwareModule, 0, len(reply.Module))
+	for _, module := range reply.Module {
+		modules = append(modules, FirmwareModule{
+			Name:            module.Name,
+			ProjectName:     module.ProjectName,
+			SoftwareVersion: module.SwVer,
+			HardwareVersion: module.HwVer,
+			SerialNumber:    module.Sn,
+		})
+	}
+
+	return modules, nil
+}
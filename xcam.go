@@ -0,0 +1,102 @@
+package bambulabs_cloud_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/torbenconto/bambulabs_cloud_api/pkg/mqtt"
+)
+
+type HaltSensitivity string
+
+const (
+	HaltSensitivityLow    HaltSensitivity = "low"
+	HaltSensitivityMedium HaltSensitivity = "medium"
+	HaltSensitivityHigh   HaltSensitivity = "high"
+)
+
+// XcamSettings are the settings of the camera-based print monitoring.
+type XcamSettings struct {
+	SpaghettiDetector        bool            `json:"spaghetti_detector"`         // Detect spaghetti failures
+	FirstLayerInspector      bool            `json:"first_layer_inspector"`      // Inspect the first layer (X1 series)
+	BuildplateMarkerDetector bool            `json:"buildplate_marker_detector"` // Check the build plate type before printing
+	PrintingMonitor          bool            `json:"printing_monitor"`           // Monitor the print with the camera
+	AllowSkipParts           bool            `json:"allow_skip_parts"`           // Allow skipping failed parts instead of stopping
+	PrintHalt                bool            `json:"print_halt"`                 // Pause the print when a failure is detected
+	HaltSensitivity          HaltSensitivity `json:"halt_sensitivity"`           // Sensitivity of the failure detection
+}
+
+// xcamModule is the reply of the printer to xcam_control_set.
+type xcamModule struct {
+	ModuleName string `json:"module_name"`
+	Control    *bool  `json:"control"`
+}
+
+// ConfigureXcam applies the given monitoring settings, one module at a time, and
+// checks that the printer acknowledged each of them. The current settings are
+// reported in Data.Xcam.
+func (p *Printer) ConfigureXcam(ctx context.Context, settings XcamSettings) error {
+	for _, command := range xcamCommands(settings) {
+		module, _ := command.Field("module_name").(string)
+		enabled, _ := command.Field("control").(bool)
+
+		raw, err := p.mqttClient.Request(ctx, p.serial, command)
+		if err != nil {
+			return fmt.Errorf("xcam_control_set %s failed: %w", module, err)
+		}
+		if err := checkXcamReply(raw, module, enabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xcamCommands builds the xcam_control_set commands applying settings. The halt
+// settings are sent along with the spaghetti detector they apply to.
+func xcamCommands(settings XcamSettings) []*mqtt.Command {
+	modules := []struct {
+		name    string
+		enabled bool
+	}{
+		{"spaghetti_detector", settings.SpaghettiDetector},
+		{"first_layer_inspector", settings.FirstLayerInspector},
+		{"buildplate_marker_detector", settings.BuildplateMarkerDetector},
+		{"printing_monitor", settings.PrintingMonitor},
+		{"allow_skip_parts", settings.AllowSkipParts},
+	}
+
+	commands := make([]*mqtt.Command, 0, len(modules))
+	for _, module := range modules {
+		command := mqtt.NewCommand(mqtt.Xcam).
+			AddCommandField("xcam_control_set").
+			AddField("module_name", module.name).
+			AddField("control", module.enabled).
+			AddField("enable", module.enabled)
+
+		if module.name == "spaghetti_detector" {
+			command.AddField("print_halt", settings.PrintHalt)
+			if settings.HaltSensitivity != "" {
+				command.AddField("halt_print_sensitivity", string(settings.HaltSensitivity))
+			}
+		}
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+// checkXcamReply returns an error unless the reply acknowledges the requested state
+// of the module.
+func checkXcamReply(raw json.RawMessage, module string, enabled bool) error {
+	var reply xcamModule
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return fmt.Errorf("xcam_control_set %s: invalid reply: %w", module, err)
+	}
+	if reply.ModuleName != "" && reply.ModuleName != module {
+		return fmt.Errorf("xcam_control_set %s: reply for module %s", module, reply.ModuleName)
+	}
+	if reply.Control != nil && *reply.Control != enabled {
+		return fmt.Errorf("xcam_control_set %s: printer kept control %t", module, *reply.Control)
+	}
+	return nil
+}
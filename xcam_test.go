@@ -0,0 +1,59 @@
+package bambulabs_cloud_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXcamCommands(t *testing.T) {
+	commands := xcamCommands(XcamSettings{
+		SpaghettiDetector: true,
+		PrintHalt:         true,
+		HaltSensitivity:   HaltSensitivityHigh,
+		AllowSkipParts:    true,
+	})
+	require.Len(t, commands, 5)
+
+	spaghetti := commandFields(t, commands[0])
+	assert.Equal(t, "xcam_control_set", spaghetti["command"])
+	assert.Equal(t, "spaghetti_detector", spaghetti["module_name"])
+	assert.Equal(t, true, spaghetti["control"])
+	assert.Equal(t, true, spaghetti["print_halt"])
+	assert.Equal(t, "high", spaghetti["halt_print_sensitivity"])
+
+	firstLayer := commandFields(t, commands[1])
+	assert.Equal(t, "first_layer_inspector", firstLayer["module_name"])
+	assert.Equal(t, false, firstLayer["control"])
+	assert.NotContains(t, firstLayer, "print_halt")
+
+	skipParts := commandFields(t, commands[4])
+	assert.Equal(t, "allow_skip_parts", skipParts["module_name"])
+	assert.Equal(t, true, skipParts["enable"])
+}
+
+func TestCheckXcamReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		wantErr bool
+	}{
+		{name: "acknowledged", reply: `{"command":"xcam_control_set","module_name":"spaghetti_detector","control":true,"result":"success"}`},
+		{name: "without echo", reply: `{"command":"xcam_control_set","result":"success"}`},
+		{name: "other module", reply: `{"command":"xcam_control_set","module_name":"first_layer_inspector","control":true}`, wantErr: true},
+		{name: "not applied", reply: `{"command":"xcam_control_set","module_name":"spaghetti_detector","control":false}`, wantErr: true},
+		{name: "invalid", reply: `[]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkXcamReply([]byte(tt.reply), "spaghetti_detector", true)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}